@@ -0,0 +1,321 @@
+package pushover
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Base url of the pushover api, replaced by tests
+var apiURL = "https://api.pushover.net/1"
+
+// Create a client for api calls. Its transport is a copy of the default transport, which
+// honors the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables. Timeouts are
+// left to the request context.
+func newClient() *http.Client {
+	return &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+}
+
+// Client for api calls without a Pushover
+var defaultClient = newClient()
+
+// Client shared by all api calls of the Pushover, created on first use
+func (p *Pushover) client() *http.Client {
+	if p == nil {
+		return defaultClient
+	}
+	st := p.state()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.client == nil {
+		st.client = p.httpClient
+	}
+	if st.client == nil {
+		st.client = newClient()
+	}
+	return st.client
+}
+
+// Close idle connections of the shared client, e.g. before a short-lived tool exits. The
+// Pushover remains usable, the next api call creates a new client or reuses the one of
+// WithHTTPClient().
+func (p *Pushover) Close() {
+	st := p.state()
+	st.mu.Lock()
+	client := st.client
+	st.client = nil
+	st.mu.Unlock()
+	if client != nil {
+		client.CloseIdleConnections()
+	}
+}
+
+// Derive a context with timeout, a zero timeout never expires
+func timeoutContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Error returned when the api does not respond in time. The message may or may not have
+// been delivered, retrying later is usually appropriate.
+var ErrTimeout = errors.New("pushover api timeout")
+
+// Timeouts are further distinguished by whether the request was sent, both match ErrTimeout.
+// A connect timeout happened before the request was completely written, the api has not
+// received the message. After a response timeout the request was written but no complete
+// response arrived, the message may have been delivered or not: the api could have
+// received it or the request may have been lost on the way. There is no way to tell.
+var (
+	ErrConnectTimeout  = fmt.Errorf("%w before request was sent", ErrTimeout)
+	ErrResponseTimeout = fmt.Errorf("%w waiting for response", ErrTimeout)
+)
+
+// Trace requests made with the context, the flag is set once a request has been written
+func traceSent(ctx context.Context) (context.Context, *atomic.Bool) {
+	sent := new(atomic.Bool)
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteRequest: func(i httptrace.WroteRequestInfo) { sent.Store(i.Err == nil) },
+	}), sent
+}
+
+// Mark timeouts of a failed request with ErrConnectTimeout or ErrResponseTimeout,
+// depending on whether the request has been sent
+func timeoutError(err error, sent bool) error {
+	var ne net.Error
+	if !errors.Is(err, context.DeadlineExceeded) && !(errors.As(err, &ne) && ne.Timeout()) {
+		return err
+	}
+	if sent {
+		return fmt.Errorf("%w: %w", ErrResponseTimeout, err)
+	}
+	return fmt.Errorf("%w: %w", ErrConnectTimeout, err)
+}
+
+// Error reported by the pushover api, either with status 0 in the response or with
+// a HTTP error status.
+type APIError struct {
+	StatusCode int           // HTTP status code of the response
+	Request    string        // request id, include it when contacting pushover support
+	Errors     []string      // human readable errors, empty if the response was not readable
+	RetryAfter time.Duration // wait requested by a Retry-After header, zero if none
+}
+
+func (e *APIError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("pushover api error: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("pushover api error: %s", strings.Join(e.Errors, ", "))
+}
+
+// Error matched by api errors rejecting the message's sound, test with errors.Is to fall
+// back to the default sound
+var ErrInvalidSound = errors.New("pushover sound is invalid")
+
+// Error matched by api errors with status 503, returned while pushover is down for
+// maintenance. Pause sending for the RetryAfter of the APIError then, if given.
+var ErrServiceUnavailable = errors.New("pushover service unavailable")
+
+// Match api errors to specific error values like ErrInvalidSound
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrServiceUnavailable:
+		return e.StatusCode == http.StatusServiceUnavailable
+	case ErrInvalidSound:
+		for _, msg := range e.Errors {
+			if strings.Contains(msg, "sound") && strings.Contains(msg, "invalid") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Check if the api rejected a request for good, sending it again will not help
+func permanent(err error) bool {
+	var ae *APIError
+	return errors.As(err, &ae) && ae.StatusCode >= 400 && ae.StatusCode < 500
+}
+
+// Fields common to all api responses
+type response struct {
+	Status  int      `json:"status"`
+	Request string   `json:"request"`
+	Errors  []string `json:"errors"`
+}
+
+// Retry a failed call if it was a network error, a server error or the api asked to slow
+// down. Used if Pushover.RetryIf is not set.
+func DefaultRetryIf(resp *http.Response, err error) bool {
+	if resp == nil {
+		return err != nil && !errors.Is(err, context.Canceled)
+	}
+	return resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// Check if a failed call must not be retried whatever RetryIf says: throttling is a client
+// side decision and requests rejected by the api fail again, except when asked to slow
+// down with status 429
+func final(err error) bool {
+	var ae *APIError
+	if errors.As(err, &ae) {
+		return ae.StatusCode >= 400 && ae.StatusCode < 500 && ae.StatusCode != http.StatusTooManyRequests
+	}
+	return errors.Is(err, ErrThrottled)
+}
+
+// Delay before the first retry, doubled for every further retry
+var retryDelay = time.Second
+
+// Error returned when retrying a failed api call would exceed Pushover.RetryBudget. The
+// error of the last attempt is wrapped, too.
+var ErrRetryBudget = errors.New("pushover retry budget exhausted")
+
+// Call the api and decode the response into out, which may be nil. Failed calls are retried
+// as configured by Retries and RetryIf, p may be nil for no retries. The returned response
+// is only useful for its status and headers, its body has been consumed already. It is
+// returned with the error if the api responded at all.
+func (p *Pushover) call(ctx context.Context, method, path, contentType string, body []byte, out any) (*http.Response, error) {
+	resp, _, err := p.callTimed(ctx, method, path, contentType, body, out)
+	return resp, err
+}
+
+// Like call(), also returns the round trip time of the last attempt, without the waits
+// between retries
+func (p *Pushover) callTimed(ctx context.Context, method, path, contentType string, body []byte, out any) (*http.Response, time.Duration, error) {
+	retries, retryIf, budget := 0, DefaultRetryIf, time.Duration(0)
+	if p != nil {
+		retries, budget = p.Retries, p.RetryBudget
+		if p.RetryIf != nil {
+			retryIf = p.RetryIf
+		}
+	}
+	client, delay, start := p.client(), retryDelay, time.Now()
+	for attempt := 0; ; attempt++ {
+		attemptStart := time.Now()
+		resp, err := do(ctx, client, method, path, contentType, body, out)
+		rtt := time.Since(attemptStart)
+		if err == nil || attempt >= retries || ctx.Err() != nil || final(err) || !retryIf(resp, err) {
+			return resp, rtt, err
+		}
+		wait := delay
+		var ae *APIError
+		if errors.As(err, &ae) && ae.RetryAfter > wait {
+			wait = ae.RetryAfter
+		}
+		if budget > 0 && time.Since(start)+wait > budget {
+			return resp, rtt, fmt.Errorf("%w after %d attempts: %w", ErrRetryBudget, attempt+1, err)
+		}
+		select {
+		case <-ctx.Done():
+			return resp, rtt, err
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
+
+// Single attempt of an api call, the body is sent from a buffer so it can be repeated
+func do(ctx context.Context, client *http.Client, method, path, contentType string, body []byte, out any) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	ctx, sent := traceSent(ctx)
+	req, err := http.NewRequestWithContext(ctx, method, apiURL+path, r)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, timeoutError(err, sent.Load())
+	}
+	defer resp.Body.Close()
+	b, err := readBody(decoded(resp))
+	if err != nil {
+		return resp, timeoutError(err, true)
+	}
+
+	// Only 500 errors will not respond a readable result
+	var res response
+	if resp.StatusCode >= http.StatusInternalServerError || json.Unmarshal(b, &res) != nil || res.Status != 1 {
+		return resp, &APIError{StatusCode: resp.StatusCode, Request: res.Request, Errors: res.Errors, RetryAfter: retryAfter(resp.Header)}
+	}
+	if out != nil {
+		if err := json.Unmarshal(b, out); err != nil {
+			return resp, fmt.Errorf("cannot decode response: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+// Wait requested by the Retry-After header in seconds or as HTTP date, zero if none
+func retryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(max(secs, 0)) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+// Body of a response, gzip decoded if the transport did not decode it already. The default
+// transport requests and decodes gzip itself, unless a custom transport or a manually set
+// Accept-Encoding header disables that.
+func decoded(resp *http.Response) io.Reader {
+	if resp.Uncompressed || !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return resp.Body
+	}
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return errReader{fmt.Errorf("cannot decode gzip response: %w", err)}
+	}
+	return gz
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+// Post form values to the api
+func (p *Pushover) postForm(ctx context.Context, path string, v url.Values, out any) (*http.Response, error) {
+	return p.call(ctx, http.MethodPost, path, "application/x-www-form-urlencoded", []byte(v.Encode()), out)
+}
+
+// Responses from pushover are small, anything larger comes from a broken or hostile
+// intermediary and is not read any further.
+const maxBody = 1 << 20
+
+var errBodyTooLarge = fmt.Errorf("response body exceeds %d bytes", maxBody)
+
+func readBody(r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, maxBody+1))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read body: %w", err)
+	}
+	if len(b) > maxBody {
+		return nil, errBodyTooLarge
+	}
+	return b, nil
+}
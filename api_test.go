@@ -0,0 +1,450 @@
+package pushover
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxyFromEnvironment(t *testing.T) {
+	// The proxy environment is read once per process, so the send runs in a child process
+	if os.Getenv("PUSHOVER_TEST_PROXY_CHILD") == "1" {
+		apiURL = "http://api.pushover.invalid/1"
+		m := message(t)
+		if err := m.SendAndWait("title", "message", 5*time.Second); err != nil {
+			t.Fatalf("cannot send through proxy: %s", err)
+		}
+		return
+	}
+
+	tr, ok := newClient().Transport.(*http.Transport)
+	if !ok || tr.Proxy == nil {
+		t.Fatalf("client transport does not use a proxy from the environment")
+	}
+
+	proxied := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied <- r.URL.String()
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	}))
+	defer proxy.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestProxyFromEnvironment$")
+	cmd.Env = append(os.Environ(), "PUSHOVER_TEST_PROXY_CHILD=1", "HTTP_PROXY="+proxy.URL, "http_proxy=", "NO_PROXY=", "no_proxy=")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("send through proxy failed: %s\n%s", err, out)
+	}
+	select {
+	case u := <-proxied:
+		if u != "http://api.pushover.invalid/1/messages.json" {
+			t.Errorf("proxy received request for %s", u)
+		}
+	default:
+		t.Errorf("request did not go through proxy")
+	}
+}
+
+func TestClose(t *testing.T) {
+	closed := make(chan struct{}, 1)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	}))
+	srv.Config.ConnState = func(c net.Conn, s http.ConnState) {
+		if s == http.StateClosed {
+			closed <- struct{}{}
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+	defer func(u string) { apiURL = u }(apiURL)
+	apiURL = srv.URL
+
+	p := load(t)
+	m := p.MustMessage("a1", "r1")
+	if err := m.SendAndWait("title", "message", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Errorf("idle connection not closed")
+	}
+	if err := m.SendAndWait("title", "message", time.Second); err != nil {
+		t.Errorf("send after close returned %s", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	defer close(release)
+	m := message(t)
+	if err := m.SendAndWait("title", "message", 20*time.Millisecond); !errors.Is(err, ErrTimeout) || !errors.Is(err, ErrResponseTimeout) {
+		t.Errorf("slow server returned %v, want ErrResponseTimeout", err)
+	}
+
+	// a dialer that never connects times out before the request is sent
+	p := load(t)
+	p.state().client = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}}
+	unreachable := p.MustMessage("a1", "r1")
+	if err := unreachable.SendAndWait("title", "message", 20*time.Millisecond); !errors.Is(err, ErrTimeout) || !errors.Is(err, ErrConnectTimeout) {
+		t.Errorf("unreachable server returned %v, want ErrConnectTimeout", err)
+	}
+
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"status":0,"errors":["application token is invalid"]}`)
+	})
+	if err := m.SendAndWait("title", "message", time.Second); err == nil || errors.Is(err, ErrTimeout) {
+		t.Errorf("rejected message returned %v, want non-timeout error", err)
+	}
+}
+
+func TestSendAndWaitCancel(t *testing.T) {
+	started := make(chan struct{}, 1)
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm() // the server notices the client going away once the body is read
+		started <- struct{}{}
+		<-r.Context().Done()
+	})
+	m := message(t)
+	cancel := make(chan struct{})
+	go func() {
+		<-started
+		close(cancel)
+	}()
+	start := time.Now()
+	if err := m.SendAndWaitCancel("title", "message", 10*time.Second, cancel); !errors.Is(err, context.Canceled) || errors.Is(err, ErrTimeout) {
+		t.Errorf("cancelled send returned %v, want context.Canceled", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("cancelled send returned after %s", d)
+	}
+
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	if err := m.SendAndWaitCancel("title", "message", time.Second, nil); err != nil {
+		t.Errorf("send without cancel returned %s", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = time.Millisecond
+
+	var attempts []string
+	failures := 2
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		attempts = append(attempts, r.PostForm.Get("message"))
+		if len(attempts) <= failures {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	p := load(t)
+	p.Retries = 3
+	m := p.MustMessage("a1", "r1")
+	if err := m.SendAndWait("title", "payload", time.Second); err != nil {
+		t.Fatalf("retried send returned error: %s", err)
+	}
+	if len(attempts) != 3 {
+		t.Errorf("sent %d attempts, want 3", len(attempts))
+	}
+	for i, a := range attempts {
+		if a != "payload" {
+			t.Errorf("attempt %d posted message %q, body not repeated", i, a)
+		}
+	}
+
+	attempts, failures = nil, 10
+	if err := m.SendAndWait("title", "payload", time.Second); err == nil {
+		t.Errorf("send failing on every attempt returned no error")
+	}
+	if len(attempts) != 4 {
+		t.Errorf("sent %d attempts, want 1 plus 3 retries", len(attempts))
+	}
+
+	attempts = nil
+	p.RetryIf = func(resp *http.Response, err error) bool { return false }
+	m.SendAndWait("title", "payload", time.Second)
+	if len(attempts) != 1 {
+		t.Errorf("sent %d attempts with custom retry predicate, want 1", len(attempts))
+	}
+}
+
+func TestNoRetryOfFinalErrors(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = time.Millisecond
+
+	attempts := 0
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"status":0,"errors":["user key is invalid"]}`)
+	})
+	p := load(t)
+	p.Retries = 5
+	p.RetryIf = func(*http.Response, error) bool { return true }
+	m := p.MustMessage("a1", "r1", WithPriority(PriorityEmergency))
+	if err := m.SendAndWait("title", "message", time.Second); err == nil || attempts != 1 {
+		t.Errorf("rejected send made %d attempts, err=%v, want 1", attempts, err)
+	}
+
+	attempts = 0
+	m.Throttle(time.Hour)
+	m.runThrottled(func() error { return nil })
+	start := time.Now()
+	if err := m.SendAndWait("title", "message", time.Second); !errors.Is(err, ErrThrottled) || attempts != 0 {
+		t.Errorf("throttled send made %d attempts, err=%v", attempts, err)
+	}
+	if _, err := m.SendEmergencyAndAwaitAck(context.Background(), "title", "message", 0, 0, time.Millisecond); !errors.Is(err, ErrThrottled) || attempts != 0 {
+		t.Errorf("throttled emergency made %d attempts, err=%v", attempts, err)
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("throttled sends took %s, want immediate return", d)
+	}
+	if !final(ErrThrottled) || final(&APIError{StatusCode: http.StatusTooManyRequests}) || final(&APIError{StatusCode: http.StatusBadGateway}) {
+		t.Errorf("wrong errors considered final")
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = 10 * time.Millisecond
+
+	attempts := 0
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	p := load(t)
+	p.Retries, p.RetryBudget = 100, 50*time.Millisecond
+	m := p.MustMessage("a1", "r1")
+	err := m.SendAndWait("title", "payload", time.Second)
+	var apiErr *APIError
+	if !errors.Is(err, ErrRetryBudget) || !errors.As(err, &apiErr) {
+		t.Fatalf("send beyond retry budget returned %v, want ErrRetryBudget with api error", err)
+	}
+	// retries after 10ms and 30ms, the next one at 70ms exceeds the budget
+	if attempts != 3 || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("retry budget stopped after %d attempts: %s", attempts, err)
+	}
+}
+
+// Transport setting Accept-Encoding manually, which disables decoding by the transport
+type manualGzip struct{}
+
+func (manualGzip) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("Accept-Encoding", "gzip")
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestGzipResponse(t *testing.T) {
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			t.Errorf("request accepts encoding %q, want gzip", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		fmt.Fprint(gz, `{"status":1,"request":"req","sounds":{"siren":"Siren"}}`)
+		gz.Close()
+	})
+	for _, client := range []*http.Client{nil, {Transport: manualGzip{}}} {
+		p := load(t)
+		if client != nil {
+			p.state().client = client
+		}
+		sounds, err := p.Sounds(context.Background(), "a1")
+		if err != nil || sounds["siren"] != "Siren" {
+			t.Errorf("gzip response decoded to %v, err=%v, custom transport=%t", sounds, err, client != nil)
+		}
+	}
+}
+
+// Transport answering every request itself, like a replaying test transport
+type replay struct{ requests *[]string }
+
+func (rt replay) RoundTrip(r *http.Request) (*http.Response, error) {
+	*rt.requests = append(*rt.requests, r.URL.Path)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(`{"status":1,"request":"replayed"}`)),
+		Request:    r,
+	}, nil
+}
+
+func TestWithRoundTripper(t *testing.T) {
+	var replayed, other []string
+	p, err := LoadFS(sampleFS, "sample.json", WithRoundTripper(replay{&replayed}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := p.MustMessage("a1", "r1")
+	if err := m.SendAndWait("title", "message", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	p.Close() // keeps the transport
+	if err := m.SendAndWait("title", "again", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed) != 2 || replayed[0] != "/1/messages.json" {
+		t.Errorf("transport got requests %v", replayed)
+	}
+
+	client := &http.Client{Transport: replay{&other}}
+	p, err = LoadFS(sampleFS, "sample.json", WithRoundTripper(replay{&replayed}), WithHTTPClient(client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m = p.MustMessage("a1", "r1")
+	if err := m.SendAndWait("title", "message", time.Second); err != nil || len(other) != 1 || len(replayed) != 2 {
+		t.Errorf("client got %d requests, transport %d, err=%v; want client to take precedence", len(other), len(replayed)-2, err)
+	}
+}
+
+func TestWithDialContext(t *testing.T) {
+	srv := mock(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	var dialed []string
+	var d net.Dialer
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, network+" "+addr)
+		return d.DialContext(ctx, "tcp4", addr)
+	}
+	p, err := LoadFS(sampleFS, "sample.json", WithDialContext(dial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := p.MustMessage("a1", "r1")
+	if err := m.SendAndWait("title", "message", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if want := "tcp " + srv.Listener.Addr().String(); len(dialed) != 1 || dialed[0] != want {
+		t.Errorf("dialed %v, want %s", dialed, want)
+	}
+}
+
+func TestServiceUnavailable(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = time.Millisecond
+
+	var attempts []time.Time
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts = append(attempts, time.Now())
+		if len(attempts) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, "down for maintenance")
+			return
+		}
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	m := message(t)
+	err := m.SendAndWait("title", "message", time.Second)
+	var apiErr *APIError
+	if !errors.Is(err, ErrServiceUnavailable) || !errors.As(err, &apiErr) || apiErr.RetryAfter != time.Second {
+		t.Fatalf("maintenance returned %v, want ErrServiceUnavailable with retry after 1s", err)
+	}
+	if errors.Is(&APIError{StatusCode: http.StatusBadGateway}, ErrServiceUnavailable) {
+		t.Errorf("502 matches ErrServiceUnavailable")
+	}
+
+	// retries wait as long as asked
+	attempts = nil
+	p := load(t)
+	p.Retries = 1
+	m = p.MustMessage("a1", "r1")
+	if err := m.SendAndWait("title", "message", 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 2 || attempts[1].Sub(attempts[0]) < time.Second {
+		t.Errorf("retried %d times, after %s", len(attempts)-1, attempts[len(attempts)-1].Sub(attempts[0]))
+	}
+	if d := retryAfter(http.Header{"Retry-After": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}}); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("retry after http date is %s, want an hour", d)
+	}
+}
+
+func TestSendRaw(t *testing.T) {
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-raw")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"status":0,"errors":["odd"]}`)
+	})
+	m := message(t)
+	resp, err := m.SendRaw(context.Background(), "title", "message")
+	if err != nil {
+		t.Fatalf("raw send returned error for api error: %s", err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusBadRequest || resp.Header.Get("X-Request-Id") != "req-raw" || string(b) != `{"status":0,"errors":["odd"]}` {
+		t.Errorf("raw response status=%d, headers=%v, body=%s", resp.StatusCode, resp.Header, b)
+	}
+}
+
+func TestInvalidSound(t *testing.T) {
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("sound") == "kazoo" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"sound":"invalid","errors":["sound is invalid"],"status":0,"request":"req"}`)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"user":"invalid","errors":["user identifier is invalid"],"status":0,"request":"req"}`)
+	})
+	m := message(t)
+	m.Set(WithSound("kazoo"))
+	var apiErr *APIError
+	if err := m.SendAndWait("title", "message", time.Second); !errors.Is(err, ErrInvalidSound) || !errors.As(err, &apiErr) {
+		t.Errorf("rejected sound returned %v, want ErrInvalidSound", err)
+	}
+	m.Set(WithSound(""))
+	if err := m.SendAndWait("title", "message", time.Second); err == nil || errors.Is(err, ErrInvalidSound) {
+		t.Errorf("rejected user returned %v, want other api error", err)
+	}
+}
+
+func TestDefaultRetryIf(t *testing.T) {
+	for _, tc := range []struct {
+		resp *http.Response
+		err  error
+		want bool
+	}{
+		{nil, errors.New("connection refused"), true},
+		{nil, context.Canceled, false},
+		{&http.Response{StatusCode: http.StatusInternalServerError}, &APIError{}, true},
+		{&http.Response{StatusCode: http.StatusTooManyRequests}, &APIError{}, true},
+		{&http.Response{StatusCode: http.StatusBadRequest}, &APIError{}, false},
+	} {
+		if got := DefaultRetryIf(tc.resp, tc.err); got != tc.want {
+			t.Errorf("DefaultRetryIf(%v, %v)=%t, want %t", tc.resp, tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package pushover
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // decoders of image.DecodeConfig
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Maximum size of an attachment accepted by pushover
+const maxAttachment = 5 << 20
+
+// Image types accepted as attachment
+var attachmentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/bmp":  true,
+	"image/webp": true,
+}
+
+// Image attached to a message, read completely before sending
+type attachment struct {
+	data           []byte
+	filename, mime string
+}
+
+// Read an attachment and validate its size and type. An empty mime type is detected
+// from the content.
+func readAttachment(r io.Reader, filename, mime string) (*attachment, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxAttachment+1))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read attachment: %w", err)
+	}
+	if len(data) > maxAttachment {
+		return nil, fmt.Errorf("attachment exceeds %d bytes", maxAttachment)
+	}
+	if mime == "" {
+		mime = http.DetectContentType(data)
+	}
+	if mt, _, _ := strings.Cut(mime, ";"); !attachmentTypes[strings.TrimSpace(mt)] {
+		return nil, fmt.Errorf("unsupported attachment type: %s", mime)
+	}
+	if filename == "" {
+		filename = "image"
+	}
+	return &attachment{data: data, filename: filename, mime: mime}, nil
+}
+
+// Error returned for attached images exceeding the dimensions set with WithMaxImageSize()
+var ErrImageTooLarge = errors.New("pushover attachment image too large")
+
+// Reject attached images wider or higher than the given number of pixels, which fit the
+// size limit but render poorly. Only the image header is decoded. Images in formats
+// without decoder in the standard library, like webp, are not checked. Zero disables
+// the check of a dimension.
+func WithMaxImageSize(width, height int) Option {
+	return func(m *Message) { m.maxWidth, m.maxHeight = width, height }
+}
+
+// Check the image dimensions of an attachment against the message's maximum
+func (m *Message) checkDimensions(a *attachment) error {
+	if m.maxWidth <= 0 && m.maxHeight <= 0 {
+		return nil
+	}
+	c, _, err := image.DecodeConfig(bytes.NewReader(a.data))
+	if err != nil {
+		return nil // unknown format
+	}
+	if m.maxWidth > 0 && c.Width > m.maxWidth || m.maxHeight > 0 && c.Height > m.maxHeight {
+		return fmt.Errorf("%w: %dx%d pixels, maximum %dx%d", ErrImageTooLarge, c.Width, c.Height, m.maxWidth, m.maxHeight)
+	}
+	return nil
+}
+
+// Encode message values and attachment as multipart form
+func (a *attachment) multipart(v url.Values) (string, []byte, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := w.WriteField(k, v.Get(k)); err != nil {
+			return "", nil, err
+		}
+	}
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="attachment"; filename=%q`, a.filename))
+	h.Set("Content-Type", a.mime)
+	part, err := w.CreatePart(h)
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := part.Write(a.data); err != nil {
+		return "", nil, err
+	}
+	if err := w.Close(); err != nil {
+		return "", nil, err
+	}
+	return w.FormDataContentType(), body.Bytes(), nil
+}
+
+// Send a message with an image attachment in background. The image is read before
+// returning, an empty mime type is detected from the content, filename may be empty
+// for in-memory images. Only image types accepted by pushover are sent, other errors
+// are handled like in Send().
+func (m *Message) SendWithAttachmentType(title, message string, r io.Reader, filename, mime string) error {
+	a, err := readAttachment(r, filename, mime)
+	if err != nil {
+		return err
+	}
+	if err := m.checkDimensions(a); err != nil {
+		return err
+	}
+	return m.sendBackground(context.Background(), m.values(title, message), a)
+}
+
+// Send a photo with a one-line caption in background, e.g. from a doorbell camera. The
+// caption is the title, the message is the filename or "Photo" if empty, since pushover
+// requires one. The image type is detected from the content, otherwise like
+// SendWithAttachmentType().
+func (m *Message) SendPhoto(caption string, r io.Reader, filename string) error {
+	message := filename
+	if message == "" {
+		message = "Photo"
+	}
+	return m.SendWithAttachmentType(caption, message, r, filename, "")
+}
@@ -0,0 +1,135 @@
+package pushover
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Smallest valid PNG header, enough for content type detection
+var pngData = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00")
+
+type upload struct {
+	fields         map[string]string
+	filename, mime string
+	data           []byte
+}
+
+// Mock server recording multipart uploads
+func mockUpload(t *testing.T) chan upload {
+	uploads := make(chan upload, 1)
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(maxAttachment); err != nil {
+			t.Errorf("cannot parse multipart form: %s", err)
+			return
+		}
+		u := upload{fields: map[string]string{}}
+		for k, v := range r.MultipartForm.Value {
+			u.fields[k] = v[0]
+		}
+		if f, h, err := r.FormFile("attachment"); err == nil {
+			u.filename, u.mime = h.Filename, h.Header.Get("Content-Type")
+			u.data, _ = io.ReadAll(f)
+		}
+		uploads <- u
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	return uploads
+}
+
+func TestSendWithAttachmentType(t *testing.T) {
+	uploads := mockUpload(t)
+	m := message(t)
+
+	for _, tc := range []struct{ filename, mime, wantName, wantMime string }{
+		{"door.png", "image/png", "door.png", "image/png"},
+		{"", "", "image", "image/png"},
+		{"snapshot", "image/jpeg", "snapshot", "image/jpeg"},
+	} {
+		if err := m.SendWithAttachmentType("Door", "ring", bytes.NewReader(pngData), tc.filename, tc.mime); err != nil {
+			t.Fatalf("cannot send attachment %q: %s", tc.filename, err)
+		}
+		m.Flush(context.Background())
+		u := <-uploads
+		if u.fields["token"] != "app1" || u.fields["user"] != "rec1" || u.fields["title"] != "Door" || u.fields["message"] != "ring" {
+			t.Errorf("attachment posted fields %v", u.fields)
+		}
+		if u.filename != tc.wantName || u.mime != tc.wantMime || !bytes.Equal(u.data, pngData) {
+			t.Errorf("attachment posted as %q (%s), %d bytes, want %q (%s)", u.filename, u.mime, len(u.data), tc.wantName, tc.wantMime)
+		}
+	}
+}
+
+func TestInvalidAttachment(t *testing.T) {
+	m := message(t)
+	if err := m.SendWithAttachmentType("t", "m", strings.NewReader("plain text"), "notes.txt", ""); err == nil {
+		t.Errorf("sent text attachment without error")
+	}
+	if err := m.SendWithAttachmentType("t", "m", bytes.NewReader(pngData), "x.pdf", "application/pdf"); err == nil {
+		t.Errorf("sent pdf attachment without error")
+	}
+	large := io.MultiReader(bytes.NewReader(pngData), bytes.NewReader(make([]byte, maxAttachment)))
+	if err := m.SendWithAttachmentType("t", "m", large, "large.png", ""); err == nil {
+		t.Errorf("sent oversized attachment without error")
+	}
+}
+
+func TestSendPhoto(t *testing.T) {
+	uploads := mockUpload(t)
+	m := message(t)
+	for _, tc := range []struct{ filename, message, wantName string }{
+		{"door.png", "door.png", "door.png"},
+		{"", "Photo", "image"},
+	} {
+		if err := m.SendPhoto("Someone at the door", bytes.NewReader(pngData), tc.filename); err != nil {
+			t.Fatalf("cannot send photo: %s", err)
+		}
+		u := <-uploads
+		if u.fields["title"] != "Someone at the door" || u.fields["message"] != tc.message || u.fields["token"] != "app1" {
+			t.Errorf("photo posted fields %v", u.fields)
+		}
+		if u.filename != tc.wantName || u.mime != "image/png" || !bytes.Equal(u.data, pngData) {
+			t.Errorf("photo attached as %s (%s), %d bytes", u.filename, u.mime, len(u.data))
+		}
+	}
+	large := io.MultiReader(bytes.NewReader(pngData), bytes.NewReader(make([]byte, maxAttachment)))
+	if err := m.SendPhoto("too large", large, "big.png"); err == nil {
+		t.Errorf("sent photo exceeding size limit")
+	}
+}
+
+func TestMaxImageSize(t *testing.T) {
+	uploads := mockUpload(t)
+	m := message(t)
+	m.Set(WithMaxImageSize(4096, 4096))
+
+	// header of a 10000x10000 pixel gif, without image data
+	huge := []byte("GIF89a\x10\x27\x10\x27\x00\x00\x00")
+	err := m.SendPhoto("huge", bytes.NewReader(huge), "huge.gif")
+	if !errors.Is(err, ErrImageTooLarge) || !strings.Contains(err.Error(), "10000x10000") {
+		t.Errorf("oversized image returned %v, want ErrImageTooLarge with dimensions", err)
+	}
+
+	var small bytes.Buffer
+	png.Encode(&small, image.NewGray(image.Rect(0, 0, 100, 50)))
+	if err := m.SendPhoto("small", &small, "small.png"); err != nil {
+		t.Fatal(err)
+	}
+	if u := <-uploads; u.mime != "image/png" {
+		t.Errorf("uploaded %s", u.mime)
+	}
+
+	m.Set(WithMaxImageSize(0, 0))
+	if err := m.SendPhoto("huge", bytes.NewReader(huge), "huge.gif"); err != nil {
+		t.Errorf("image without limit returned %s", err)
+	}
+	<-uploads
+	m.Flush(context.Background())
+}
@@ -0,0 +1,51 @@
+package pushover
+
+import (
+	"context"
+	"sync"
+)
+
+// Message to send with SendMany()
+type SendJob struct {
+	Message *Message
+	Title   string
+	Body    string
+}
+
+// Send many distinct messages, waiting for all results. Up to concurrency sends run in
+// parallel, each message keeps its throttle. Returns the error of every job at the
+// job's index, jobs not started before the context is done return the context error.
+func SendMany(ctx context.Context, jobs []SendJob, concurrency int) Errors {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	errs := make(Errors, len(jobs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		if err := acquire(ctx, sem); err != nil {
+			errs[i] = err
+			continue
+		}
+		wg.Add(1)
+		go func(i int, job SendJob) {
+			defer func() { <-sem; wg.Done() }()
+			errs[i] = job.Message.sendWait(ctx, job.Message.values(job.Title, job.Body), nil, nil)
+		}(i, job)
+	}
+	wg.Wait()
+	return errs
+}
+
+// Acquire a slot of semaphore sem unless the context is done
+func acquire(ctx context.Context, sem chan struct{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case sem <- struct{}{}:
+		return nil
+	}
+}
@@ -0,0 +1,71 @@
+package pushover
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSendMany(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for m := maxRunning.Load(); n > m && !maxRunning.CompareAndSwap(m, n); m = maxRunning.Load() {
+		}
+		time.Sleep(5 * time.Millisecond)
+		r.ParseForm()
+		if r.PostForm.Get("message") == "bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":0,"errors":["message is invalid"]}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	p := load(t)
+	throttled := p.MustMessage("a1", "r2")
+	throttled.Throttle(time.Hour)
+
+	var jobs []SendJob
+	for i := 0; i < 10; i++ {
+		m := p.MustMessage("a1", "r1")
+		jobs = append(jobs, SendJob{Message: &m, Title: "job", Body: fmt.Sprint(i)})
+	}
+	jobs = append(jobs, SendJob{Message: jobs[0].Message, Title: "job", Body: "bad"})
+	jobs = append(jobs, SendJob{Message: &throttled, Title: "job", Body: "first"})
+	jobs = append(jobs, SendJob{Message: &throttled, Title: "job", Body: "second"})
+
+	errs := SendMany(context.Background(), jobs, 3)
+	if len(errs) != len(jobs) {
+		t.Fatalf("got %d results for %d jobs", len(errs), len(jobs))
+	}
+	var failed, throttledErrs int
+	for i, err := range errs {
+		switch {
+		case err == ErrThrottled:
+			throttledErrs++
+		case err != nil:
+			failed++
+			if i != 10 {
+				t.Errorf("job %d failed: %s", i, err)
+			}
+		}
+	}
+	if failed != 1 || throttledErrs != 1 {
+		t.Errorf("%d jobs failed, %d throttled, want 1 each", failed, throttledErrs)
+	}
+	if maxRunning.Load() > 3 {
+		t.Errorf("ran %d sends concurrently, want at most 3", maxRunning.Load())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i, err := range SendMany(ctx, jobs[:3], 1) {
+		if err != context.Canceled {
+			t.Errorf("job %d of cancelled batch returned %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package pushover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Result of a Broadcast() to a single receiver
+type BroadcastResult struct {
+	Receiver  string // receiver name from the config
+	Request   string // request id of the api, empty if the api was not reached
+	Err       error  // nil if the message was delivered to the api
+	Throttled bool   // not sent because of a pair throttle, Err is ErrThrottled then
+	Skipped   bool   // not sent because the receiver is unknown, see SkipUnknownReceivers
+}
+
+// Results of a Broadcast() at the index of the receiver
+type BroadcastResults []BroadcastResult
+
+// Errors of all receivers as MultiError, nil if the message was delivered to all
+func (r BroadcastResults) Err() error {
+	errs := make(Errors, len(r))
+	for i := range r {
+		errs[i] = r[i].Err
+	}
+	return errs.Err()
+}
+
+// Send a message of app to several receivers of the config, waiting for all results. The
+// options apply to the messages of all receivers, pair throttles set with ThrottlePair()
+// are respected. Returns the result for every receiver at the receiver's index, so
+// failed receivers can be logged and retried selectively. Unknown receivers fail unless
+// SkipUnknownReceivers is set.
+func (p *Pushover) Broadcast(ctx context.Context, app string, receivers []string, title, message string, opts ...Option) BroadcastResults {
+	results := make(BroadcastResults, len(receivers))
+	sem := make(chan struct{}, checkConcurrency)
+	var wg sync.WaitGroup
+	for i, rec := range receivers {
+		results[i].Receiver = rec
+		if p.skip(rec) {
+			results[i].Skipped = true
+			continue
+		}
+		m, err := p.Message(app, rec, opts...)
+		if err == nil {
+			err = acquire(ctx, sem)
+		}
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		wg.Add(1)
+		go func(r *BroadcastResult, m Message) {
+			defer func() { <-sem; wg.Done() }()
+			var resp response
+			r.Err = m.sendWait(ctx, m.values(title, message), nil, &resp)
+			r.Request = resp.Request
+			var apiErr *APIError
+			if errors.As(r.Err, &apiErr) {
+				r.Request = apiErr.Request
+			}
+			r.Throttled = errors.Is(r.Err, ErrThrottled)
+		}(&results[i], m)
+	}
+	wg.Wait()
+	return results
+}
+
+// Maximum number of receiver keys in a single api request
+const maxBatch = 50
+
+// Error returned for batches not sent because the monthly quota of the app would be
+// exceeded, see WouldExceedQuota()
+var ErrQuotaExceeded = errors.New("pushover monthly quota would be exceeded")
+
+// Result of a BroadcastBatched() request
+type BatchResult struct {
+	Receivers []string // receiver names from the config
+	Request   string   // request id of the api, empty if the api was not reached
+	Err       error    // nil if the message was delivered to the api
+	Skipped   bool     // receivers unknown and skipped, see SkipUnknownReceivers
+}
+
+// Send a message of app to several receivers of the config with as few api requests as
+// possible, by posting comma separated lists of up to 50 receiver keys. Device
+// restrictions of the receivers do not apply, options apply to all batches. Batches are
+// sent one after the other, batches that would exceed the monthly quota observed after
+// the previous batch return ErrQuotaExceeded. Receivers held back by a pair throttle are
+// returned as a result with ErrThrottled. An unknown receiver is an error before anything
+// is sent, with SkipUnknownReceivers unknown receivers are returned as a last result
+// with Skipped set.
+func (p *Pushover) BroadcastBatched(ctx context.Context, app string, receivers []string, title, message string, opts ...Option) ([]BatchResult, error) {
+	token, ok := p.App[app]
+	if !ok {
+		return nil, fmt.Errorf("invalid pushover application: %s", app)
+	}
+	var send, throttled, skipped []string
+	for _, rec := range receivers {
+		if p.skip(rec) {
+			skipped = append(skipped, rec)
+		} else if _, ok := p.Rec[rec]; !ok {
+			return nil, fmt.Errorf("invalid pushover receiver: %s", rec)
+		}
+	}
+	now := p.now()
+	for _, rec := range receivers {
+		if p.skip(rec) {
+			continue
+		}
+		if p.pairThrottled(app, rec, now) {
+			throttled = append(throttled, rec)
+		} else {
+			send = append(send, rec)
+		}
+	}
+
+	var results []BatchResult
+	for len(send) > 0 {
+		batch := send[:min(len(send), maxBatch)]
+		send = send[len(batch):]
+		if quota := (Message{p: p, app: token}); quota.WouldExceedQuota(len(batch)) {
+			// checked before the pair throttles are consumed by a send
+			results = append(results, BatchResult{Receivers: batch, Err: ErrQuotaExceeded})
+			continue
+		}
+		var names, keys []string
+		for _, rec := range batch {
+			if p.passPair(app, rec, now) {
+				names, keys = append(names, rec), append(keys, p.Rec[rec].Key)
+			} else {
+				throttled = append(throttled, rec) // consumed by a concurrent send meanwhile
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+		m := Message{p: p, app: token, rec: strings.Join(keys, ","), appName: app, batch: names, st: &msgState{}}
+		m.Set(opts...)
+		r := BatchResult{Receivers: names}
+		var resp response
+		r.Err = m.sendWait(ctx, m.values(title, message), nil, &resp)
+		r.Request = resp.Request
+		var apiErr *APIError
+		if errors.As(r.Err, &apiErr) {
+			r.Request = apiErr.Request
+		}
+		results = append(results, r)
+	}
+	if len(throttled) > 0 {
+		results = append(results, BatchResult{Receivers: throttled, Err: ErrThrottled})
+	}
+	if len(skipped) > 0 {
+		results = append(results, BatchResult{Receivers: skipped, Skipped: true})
+	}
+	return results, nil
+}
+
+// Check if the pair throttle of app and rec holds back a message, without consuming it
+func (p *Pushover) pairThrottled(app, rec string, now time.Time) bool {
+	st := p.state()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	pt := st.pairs[pairKey(app, rec)]
+	return pt != nil && pt.throttled(now)
+}
+
+// Check if a receiver is left out of broadcasts because it is unknown
+func (p *Pushover) skip(rec string) bool {
+	_, ok := p.Rec[rec]
+	return !ok && p.SkipUnknownReceivers
+}
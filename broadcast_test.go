@@ -0,0 +1,150 @@
+package pushover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBroadcast(t *testing.T) {
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("title") == "Deploy" && r.PostForm.Get("priority") != "1" {
+			t.Errorf("broadcast posted priority %q, want options applied", r.PostForm.Get("priority"))
+		}
+		if r.PostForm.Get("user") == "rec3" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":0,"errors":["user key is invalid"],"request":"req-bad"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"status":1,"request":"req-%s"}`, r.PostForm.Get("user"))
+	})
+	p := load(t)
+	p.ThrottlePair("a1", "r2", time.Hour)
+	m := p.MustMessage("a1", "r2")
+	m.SendAndWait("before", "broadcast", time.Second)
+
+	results := p.Broadcast(context.Background(), "a1", []string{"r1", "r2", "work", "missing"}, "Deploy", "done", WithPriority(PriorityHigh))
+	if len(results) != 4 {
+		t.Fatalf("broadcast returned %d results, want 4", len(results))
+	}
+	if r := results[0]; r.Receiver != "r1" || r.Err != nil || r.Request != "req-rec1" || r.Throttled {
+		t.Errorf("delivered receiver result %+v", r)
+	}
+	if r := results[1]; r.Receiver != "r2" || !errors.Is(r.Err, ErrThrottled) || !r.Throttled || r.Request != "" {
+		t.Errorf("throttled receiver result %+v", r)
+	}
+	var apiErr *APIError
+	if r := results[2]; r.Receiver != "work" || !errors.As(r.Err, &apiErr) || r.Request != "req-bad" || r.Throttled {
+		t.Errorf("rejected receiver result %+v", r)
+	}
+	if r := results[3]; r.Receiver != "missing" || r.Err == nil || r.Throttled {
+		t.Errorf("unknown receiver result %+v", r)
+	}
+}
+
+func TestBroadcastBatched(t *testing.T) {
+	var batches []int
+	remaining := 1000
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		n := len(strings.Split(r.PostForm.Get("user"), ","))
+		batches = append(batches, n)
+		remaining -= n
+		w.Header().Set("X-Limit-App-Remaining", fmt.Sprint(remaining))
+		fmt.Fprintf(w, `{"status":1,"request":"req-%d"}`, len(batches))
+	})
+	p := load(t)
+	var receivers []string
+	for i := 0; i < 120; i++ {
+		name := fmt.Sprint("user", i)
+		p.Rec[name] = Receiver{Key: fmt.Sprint("key", i)}
+		receivers = append(receivers, name)
+	}
+	check := func(results []BatchResult, want []BatchResult) {
+		t.Helper()
+		if len(results) != len(want) {
+			t.Fatalf("got %d results, want %d batches", len(results), len(want))
+		}
+		for i, w := range want {
+			r := results[i]
+			if r.Receivers[0] != w.Receivers[0] || len(r.Receivers) != len(w.Receivers) || r.Request != w.Request || r.Err != w.Err {
+				t.Errorf("batch %d is %s.. (%d) request %q err %v, want %s.. (%d) request %q err %v",
+					i, r.Receivers[0], len(r.Receivers), r.Request, r.Err, w.Receivers[0], len(w.Receivers), w.Request, w.Err)
+			}
+		}
+	}
+	results, err := p.BroadcastBatched(context.Background(), "a1", receivers, "Maintenance", "tonight", WithPriority(PriorityHigh))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 3 || batches[0] != 50 || batches[1] != 50 || batches[2] != 20 {
+		t.Errorf("posted batches of %v receivers, want 50, 50 and 20", batches)
+	}
+	check(results, []BatchResult{
+		{Receivers: receivers[:50], Request: "req-1"},
+		{Receivers: receivers[50:100], Request: "req-2"},
+		{Receivers: receivers[100:], Request: "req-3"},
+	})
+
+	// 60 remaining after the first batch, 10 after the second
+	remaining, batches = 110, nil
+	p.ThrottlePair("a1", "user110", time.Hour)
+	results, _ = p.BroadcastBatched(context.Background(), "a1", receivers, "Maintenance", "tonight")
+	check(results, []BatchResult{
+		{Receivers: receivers[:50], Request: "req-1"},
+		{Receivers: receivers[50:100], Request: "req-2"},
+		{Receivers: receivers[100:], Err: ErrQuotaExceeded},
+	})
+	if p.pairThrottled("a1", "user110", p.now()) {
+		t.Errorf("batch rejected for quota consumed the pair throttle")
+	}
+
+	p = load(t)
+	p.ThrottlePair("a1", "r2", time.Hour)
+	p.BroadcastBatched(context.Background(), "a1", []string{"r2"}, "t", "m") // consumes the pair throttle
+	batches = nil
+	results, err = p.BroadcastBatched(context.Background(), "a1", []string{"r1", "r2"}, "t", "m")
+	if err != nil || len(results) != 2 || len(batches) != 1 || batches[0] != 1 || results[1].Receivers[0] != "r2" || results[1].Err != ErrThrottled {
+		t.Errorf("pair throttled broadcast posted %v, returned %+v, err=%v", batches, results, err)
+	}
+	if _, err := p.BroadcastBatched(context.Background(), "a1", []string{"r1", "missing"}, "t", "m"); err == nil {
+		t.Errorf("broadcast to unknown receiver returned no error")
+	}
+
+	p = load(t)
+	p.BroadcastBatched(context.Background(), "a1", []string{"r1", "r2"}, "t", "m")
+	if p.LastLatency("r1") <= 0 || p.LastLatency("r2") <= 0 || p.LastLatency("r1,r2") != 0 {
+		t.Errorf("batch latency recorded as r1 %s, r2 %s, r1,r2 %s", p.LastLatency("r1"), p.LastLatency("r2"), p.LastLatency("r1,r2"))
+	}
+}
+
+func TestSkipUnknownReceivers(t *testing.T) {
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	p := load(t)
+	receivers := []string{"r1", "pager", "r2"}
+	if results := p.Broadcast(context.Background(), "a1", receivers, "t", "m"); results[1].Err == nil || results[1].Skipped {
+		t.Errorf("unknown receiver returned %+v, want error", results[1])
+	}
+
+	p.SkipUnknownReceivers = true
+	results := p.Broadcast(context.Background(), "a1", receivers, "t", "m")
+	if err := results.Err(); err != nil {
+		t.Errorf("broadcast skipping unknown receivers returned %s", err)
+	}
+	for i, r := range results {
+		if r.Skipped != (r.Receiver == "pager") || r.Skipped && r.Request != "" || !r.Skipped && r.Request != "req" {
+			t.Errorf("result %d is %+v", i, r)
+		}
+	}
+	batches, err := p.BroadcastBatched(context.Background(), "a1", receivers, "t", "m")
+	if err != nil || len(batches) != 2 || len(batches[0].Receivers) != 2 || !batches[1].Skipped || batches[1].Receivers[0] != "pager" {
+		t.Errorf("batched broadcast returned %+v, err=%v", batches, err)
+	}
+}
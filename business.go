@@ -0,0 +1,46 @@
+package pushover
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Business hours of the receivers, to page harder when no one is watching. Messages with
+// PriorityHigh sent outside the window are sent as emergency, repeated every Retry seconds
+// until acknowledged or Expire seconds have passed, zero for the defaults. In the config file:
+//
+//	"business_hours": {"start": "09:00", "end": "17:00", "timezone": "Europe/Berlin", "retry": 120}
+type BusinessHours struct {
+	DailyWindow
+	Retry  int `json:"retry,omitempty"`
+	Expire int `json:"expire,omitempty"`
+}
+
+// Check the business hours settings, used when loading a config
+func (b *BusinessHours) check() error {
+	if b == nil {
+		return nil
+	}
+	if _, err := b.contains(time.Time{}); err != nil {
+		return err
+	}
+	return checkEmergency(time.Duration(b.Retry)*time.Second, time.Duration(b.Expire)*time.Second)
+}
+
+// Escalate message values sent at now if outside business hours
+func (b *BusinessHours) apply(v url.Values, now time.Time) error {
+	if b == nil {
+		return nil
+	}
+	priority, _ := strconv.Atoi(v.Get("priority"))
+	if Priority(priority) != PriorityHigh {
+		return nil
+	}
+	business, err := b.contains(now)
+	if err != nil || business {
+		return err
+	}
+	setEmergency(v, time.Duration(b.Retry)*time.Second, time.Duration(b.Expire)*time.Second)
+	return nil
+}
@@ -0,0 +1,64 @@
+package pushover
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBusinessHours(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone data: %s", err)
+	}
+	var got []string
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		got = append(got, r.PostForm.Get("priority")+"/"+r.PostForm.Get("retry"))
+		w.Write([]byte(`{"status":1,"request":"req"}`))
+	})
+	p := load(t)
+	p.BusinessHours = &BusinessHours{DailyWindow: DailyWindow{Start: "09:00", End: "17:00", TimeZone: "America/New_York"}, Retry: 120}
+	clock := &fakeClock{}
+	p.clock = clock
+	m := p.MustMessage("a1", "r1", WithPriority(PriorityHigh))
+	for _, tc := range []struct {
+		hour, min int
+		want      string
+	}{
+		{8, 59, "2/120"},
+		{9, 0, "1/"},
+		{16, 59, "1/"},
+		{17, 0, "2/120"},
+	} {
+		// sent from UTC, compared in New York time
+		clock.t = time.Date(2024, 7, 15, tc.hour, tc.min, 0, 0, newYork).UTC()
+		got = nil
+		if err := m.SendAndWait("disk full", "on web01", time.Second); err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0] != tc.want {
+			t.Errorf("at %02d:%02d sent priority/retry %v, want %s", tc.hour, tc.min, got, tc.want)
+		}
+	}
+
+	// only high priority messages are escalated
+	got = nil
+	clock.t = time.Date(2024, 7, 15, 3, 0, 0, 0, newYork)
+	n := p.MustMessage("a1", "r1")
+	if err := n.SendAndWait("backup done", "nightly", time.Second); err != nil || len(got) != 1 || got[0] != "/" {
+		t.Errorf("normal priority message sent with priority/retry %v, err=%v", got, err)
+	}
+}
+
+func TestBusinessHoursConfig(t *testing.T) {
+	p, err := LoadReader(strings.NewReader(`{"business_hours": {"start": "09:00", "end": "17:00", "expire": 600}}`))
+	if err != nil || p.BusinessHours == nil || p.BusinessHours.Expire != 600 || p.BusinessHours.Start != "09:00" {
+		t.Errorf("loaded business hours %+v, err=%v", p.BusinessHours, err)
+	}
+	if _, err := LoadReader(strings.NewReader(`{"business_hours": {"start": "9am", "end": "17:00"}}`)); !errors.Is(err, ErrConfigInvalid) {
+		t.Errorf("loaded invalid business hours, err=%v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package pushover
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Config file used by RunCLI() without -config
+const defaultConfig = "/usr/local/etc/pushover.json"
+
+// Run a small pushover command line tool, so programs can embed it, e.g. with
+// os.Exit(pushover.RunCLI(os.Args[1:], os.Stdout, os.Stderr)). Args start with the
+// command:
+//
+//	send [-config file] [-app name] [-rec name] [-title title] [-priority n] message...
+//	validate [-config file]
+//	sounds [-config file] [-app name]
+//
+// Without -app or -rec the first configured ones are used, see First(). Returns the exit
+// code, 0 on success, 1 if the command failed and 2 for invalid usage.
+func RunCLI(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "usage: pushover send|validate|sounds [flags]")
+		return 2
+	}
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	config := fs.String("config", defaultConfig, "pushover config file")
+	var app, rec, title *string
+	var priority *int
+	switch args[0] {
+	case "send":
+		app, rec = fs.String("app", "", "application name"), fs.String("rec", "", "receiver name")
+		title = fs.String("title", "", "message title")
+		priority = fs.Int("priority", 0, "message priority from -2 to 2")
+	case "sounds":
+		app = fs.String("app", "", "application name")
+	case "validate":
+	default:
+		fmt.Fprintf(stderr, "unknown command %q, want send, validate or sounds\n", args[0])
+		return 2
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	p, err := Load(*config)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	firstApp, firstRec, _ := p.First()
+	if app != nil && *app == "" {
+		*app = firstApp
+	}
+	if rec != nil && *rec == "" {
+		*rec = firstRec
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "send":
+		if fs.NArg() == 0 {
+			fmt.Fprintln(stderr, "no message to send")
+			return 2
+		}
+		m, err := p.Message(*app, *rec, WithPriority(Priority(*priority)))
+		if err == nil {
+			err = m.SendAndWait(*title, strings.Join(fs.Args(), " "), defaultTimeout)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	case "validate":
+		results := p.ValidateAll(ctx)
+		for _, name := range sortedNames(results) {
+			if err := results[name]; err != nil {
+				fmt.Fprintf(stdout, "%s: %s\n", name, err)
+			} else {
+				fmt.Fprintf(stdout, "%s: ok\n", name)
+			}
+		}
+		if results.Err() != nil {
+			return 1
+		}
+	case "sounds":
+		sounds, err := p.Sounds(ctx, *app)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		for _, name := range sortedNames(sounds) {
+			fmt.Fprintf(stdout, "%s\t%s\n", name, sounds[name])
+		}
+	}
+	return 0
+}
@@ -0,0 +1,50 @@
+package pushover
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestRunCLI(t *testing.T) {
+	posted := make(chan string, 1)
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.URL.Path {
+		case "/sounds.json":
+			fmt.Fprint(w, `{"status":1,"sounds":{"siren":"Siren","bike":"Bike"}}`)
+		case "/users/validate.json":
+			fmt.Fprint(w, `{"status":1,"devices":["iphone"]}`)
+		default:
+			posted <- r.PostForm.Get("user") + " " + r.PostForm.Get("priority") + " " + r.PostForm.Get("title") + ": " + r.PostForm.Get("message")
+			fmt.Fprint(w, `{"status":1,"request":"req"}`)
+		}
+	})
+	run := func(args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := RunCLI(args, &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	if code, _, stderr := run("send", "-config", "sample.json", "-rec", "work", "-title", "Disk", "-priority", "1", "almost", "full"); code != 0 {
+		t.Errorf("send exited with %d: %s", code, stderr)
+	}
+	if got := <-posted; got != "rec3 1 Disk: almost full" {
+		t.Errorf("send posted %q", got)
+	}
+	if code, stdout, stderr := run("sounds", "-config", "sample.json"); code != 0 || stdout != "bike\tBike\nsiren\tSiren\n" {
+		t.Errorf("sounds exited with %d, printed %q %s", code, stdout, stderr)
+	}
+	if code, stdout, _ := run("validate", "-config", "sample.json"); code != 1 || stdout != "r1: ok\nr2: ok\nwork: pushover receiver has no active devices: work\n" {
+		t.Errorf("validate exited with %d, printed %q", code, stdout)
+	}
+	for _, args := range [][]string{nil, {"fly"}, {"send", "-config", "sample.json"}, {"send", "-bogus"}} {
+		if code, _, _ := run(args...); code != 2 {
+			t.Errorf("%v exited with %d, want usage error", args, code)
+		}
+	}
+	if code, _, stderr := run("send", "-config", "missing.json", "hello"); code != 1 || stderr == "" {
+		t.Errorf("send with missing config exited with %d, printed %q", code, stderr)
+	}
+}
@@ -0,0 +1,19 @@
+package pushover
+
+import "time"
+
+// Source of the current time for throttling, deduplication and quiet hours, replaced by
+// tests to advance time without sleeping
+type clock interface {
+	Now() time.Time
+}
+
+// Current time of the message's Pushover clock, real time if none is set
+func (m *Message) now() time.Time { return m.p.now() }
+
+func (p *Pushover) now() time.Time {
+	if p == nil || p.clock == nil {
+		return time.Now()
+	}
+	return p.clock.Now()
+}
@@ -0,0 +1,54 @@
+package pushover
+
+import "context"
+
+// Create a Message for related alerts sharing groupKey, a client side convenience to
+// collapse them since pushover has no threads. All messages created for the same app,
+// receiver and group key share one throttle and send state, so Throttle() limits the
+// group as a whole and resets the group's timer. Messages sent without title get the
+// group key as title.
+// Combined with SendIfChanged() only changes of the group's state are sent:
+//
+//	m, _ := p.GroupMessage("HomeControl", "InfoGroup", "backup")
+//	m.Throttle(time.Minute)
+//	m.SendIfChanged("", status)
+func (p *Pushover) GroupMessage(app, receiver, groupKey string) (Message, error) {
+	m, err := p.Message(app, receiver)
+	if err != nil {
+		return m, err
+	}
+	st := p.state()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	key := pairKey(app, receiver) + "\x00" + groupKey
+	if st.groups[key] == nil {
+		st.groups[key] = &msgState{group: true}
+	}
+	m.st, m.group = st.groups[key], groupKey
+	return m, nil
+}
+
+// Send message in background like Send() unless title and message equal the last message
+// sent, returns ErrDuplicate then. For group messages the last message of the group
+// counts, see GroupMessage().
+func (m *Message) SendIfChanged(title, message string) error {
+	v := m.values(title, message)
+	key := contentHash(v.Get("title"), v.Get("message"))
+	st := m.state()
+	st.mu.Lock()
+	if st.hasLast && st.last == key {
+		st.mu.Unlock()
+		return ErrDuplicate
+	}
+	prev, hadLast := st.last, st.hasLast
+	st.last, st.hasLast = key, true
+	st.mu.Unlock()
+	err := m.sendBackground(context.Background(), v, nil)
+	if err != nil {
+		// not sent, the previous message is still the last one
+		st.mu.Lock()
+		st.last, st.hasLast = prev, hadLast
+		st.mu.Unlock()
+	}
+	return err
+}
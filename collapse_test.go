@@ -0,0 +1,82 @@
+package pushover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestGroupMessage(t *testing.T) {
+	posted := make(chan url.Values, 10)
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		posted <- r.PostForm
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	p := load(t)
+	m1, err := p.GroupMessage("a1", "r1", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2, _ := p.GroupMessage("a1", "r1", "backup")
+	other, _ := p.GroupMessage("a1", "r1", "disk")
+
+	if err := m1.SendIfChanged("", "running"); err != nil {
+		t.Fatal(err)
+	}
+	if v := <-posted; v.Get("title") != "backup" || v.Get("message") != "running" {
+		t.Errorf("group message posted title %q, message %q", v.Get("title"), v.Get("message"))
+	}
+	if err := m2.SendIfChanged("", "running"); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("unchanged group message returned %v, want ErrDuplicate", err)
+	}
+	if err := other.SendIfChanged("", "running"); err != nil {
+		t.Errorf("message of another group returned %s", err)
+	}
+	<-posted
+
+	m1.Throttle(time.Hour)
+	m2.Throttle(time.Hour)
+	m1.Send("", "first")
+	<-posted
+	if err := m2.SendIfChanged("", "done"); !errors.Is(err, ErrThrottled) {
+		t.Errorf("group throttle not shared, err=%v", err)
+	}
+	m2.ResetThrottle()
+	if err := m2.SendIfChanged("", "done"); err != nil {
+		t.Errorf("changed group message returned %v after throttled attempt", err)
+	}
+	<-posted
+	m1.Flush(context.Background())
+
+	if _, err := p.GroupMessage("a1", "missing", "backup"); err == nil {
+		t.Errorf("created group message for unknown receiver")
+	}
+}
+
+func TestGroupThrottle(t *testing.T) {
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	p := load(t)
+	m1, _ := p.GroupMessage("a1", "r1", "backup")
+	m2, _ := p.GroupMessage("a1", "r1", "backup")
+	m1.Throttle(time.Hour)
+	if d := m2.ThrottleInterval(); d != time.Hour {
+		t.Errorf("group message has throttle interval %s, want 1h", d)
+	}
+	if err := m2.SendAndWait("", "first", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := m1.Send("", "second"); !errors.Is(err, ErrThrottled) {
+		t.Errorf("throttle set on one group message not applied to the group, err=%v", err)
+	}
+	m2.Throttle(0)
+	if err := m1.SendAndWait("", "third", time.Second); err != nil {
+		t.Errorf("throttle cleared on one group message still applied, err=%v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package pushover
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"time"
+)
+
+// Error returned when a message with the same content was sent within the dedup window
+var ErrDuplicate = errors.New("pushover duplicate message suppressed")
+
+// Number of recent messages remembered for deduplication, the oldest is dropped first
+const maxRecent = 64
+
+// Suppress messages with the same title and text as a message sent within the window,
+// they return ErrDuplicate. Unlike throttling, messages with different content are still
+// sent, which prevents alert storms of repeated identical events. Up to 64 recent
+// messages are remembered. A zero window disables deduplication.
+func (m *Message) DedupWindow(d time.Duration) {
+	m.dedup = d
+	st := m.state()
+	st.mu.Lock()
+	st.recent = nil
+	st.mu.Unlock()
+}
+
+// Stable key for the content of a message, used by DedupWindow() and for caller side dedup
+// caches. The key is the 64 bit FNV-1a hash of title, a NUL byte and message as 16 hex
+// digits. It does not change between processes or versions of this package, so it can be
+// persisted. Options and receiver are not part of the key.
+func (m *Message) ContentKey(title, message string) string {
+	return fmt.Sprintf("%016x", contentHash(title, message))
+}
+
+func contentHash(title, message string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(title))
+	h.Write([]byte{0})
+	h.Write([]byte(message))
+	return h.Sum64()
+}
+
+// Run fn for message values v unless the message is invalid, a duplicate or throttled
+func (m *Message) gate(v url.Values, fn func() error) error {
+	return m.gateAsync(v, func(done func(error)) error {
+		err := fn()
+		done(err)
+		return err
+	})
+}
+
+// Like gate() for a fn reporting the result of the send to done, see runThrottledAsync()
+func (m *Message) gateAsync(v url.Values, fn func(done func(error)) error) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	if err := m.checkLength(v); err != nil {
+		return err
+	}
+	if m.p != nil {
+		if err := m.p.BusinessHours.apply(v, m.now()); err != nil {
+			return err
+		}
+		if err := m.p.QuietHours.apply(v, m.now()); err != nil {
+			return err
+		}
+	}
+	m.conserve(v)
+	m.p.annotate(v)
+	if m.dedup <= 0 {
+		return m.runThrottledAsync(fn)
+	}
+	key := contentHash(v.Get("title"), v.Get("message"))
+	now := m.now()
+	st := m.state()
+	// reserved while the send is in flight, so a concurrent duplicate is suppressed,
+	// and forgotten if the send is throttled or fails, so it can be retried
+	st.mu.Lock()
+	if sent, ok := st.recent[key]; ok && now.Sub(sent) < m.dedup {
+		st.mu.Unlock()
+		return ErrDuplicate
+	}
+	st.remember(key, now, m.dedup)
+	st.mu.Unlock()
+	called := false
+	err := m.runThrottledAsync(func(done func(error)) error {
+		called = true
+		return fn(func(err error) {
+			if err != nil {
+				st.forget(key, now)
+			}
+			done(err)
+		})
+	})
+	if !called {
+		st.forget(key, now)
+	}
+	return err
+}
+
+// Remember a sent message, dropping expired and, if still full, the oldest entries.
+// st.mu must be held.
+func (st *msgState) remember(key uint64, now time.Time, window time.Duration) {
+	if st.recent == nil {
+		st.recent = map[uint64]time.Time{}
+	}
+	if len(st.recent) >= maxRecent {
+		var oldest uint64
+		var oldestAt time.Time
+		for k, at := range st.recent {
+			if now.Sub(at) >= window {
+				delete(st.recent, k)
+			} else if oldestAt.IsZero() || at.Before(oldestAt) {
+				oldest, oldestAt = k, at
+			}
+		}
+		if len(st.recent) >= maxRecent {
+			delete(st.recent, oldest)
+		}
+	}
+	st.recent[key] = now
+}
+
+// Drop key if it is still remembered as sent at now
+func (st *msgState) forget(key uint64, now time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if at, ok := st.recent[key]; ok && at.Equal(now) {
+		delete(st.recent, key)
+	}
+}
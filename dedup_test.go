@@ -0,0 +1,115 @@
+package pushover
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestDedupWindow(t *testing.T) {
+	m := message(t)
+	m.DedupWindow(time.Hour)
+	count := 0
+	send := func(title, message string) error {
+		return m.gate(url.Values{"title": {title}, "message": {message}}, func() error { count++; return nil })
+	}
+
+	if err := send("disk", "full"); err != nil {
+		t.Fatalf("first message returned error: %s", err)
+	}
+	if err := send("disk", "full"); err != ErrDuplicate {
+		t.Errorf("repeated message returned %v, want ErrDuplicate", err)
+	}
+	if err := send("disk", "ok"); err != nil {
+		t.Errorf("message with different content returned %v", err)
+	}
+	if err := send("disk full", ""); err != nil {
+		t.Errorf("message with same concatenated content returned %v", err)
+	}
+	if count != 3 {
+		t.Errorf("sent %d messages, want 3", count)
+	}
+
+	// an expired entry is sent again
+	m.st.recent[contentHash("disk", "full")] = time.Now().Add(-2 * time.Hour)
+	if err := send("disk", "full"); err != nil {
+		t.Errorf("message outside dedup window returned %v", err)
+	}
+}
+
+func TestDedupCapacity(t *testing.T) {
+	m := message(t)
+	m.DedupWindow(time.Hour)
+	for i := 0; i < 3*maxRecent; i++ {
+		m.gate(url.Values{"message": {fmt.Sprint(i)}}, func() error { return nil })
+	}
+	if len(m.st.recent) > maxRecent {
+		t.Errorf("remembered %d messages, want at most %d", len(m.st.recent), maxRecent)
+	}
+	if err := m.gate(url.Values{"message": {fmt.Sprint(3*maxRecent - 1)}}, func() error { return nil }); err != ErrDuplicate {
+		t.Errorf("latest message not remembered, err=%v", err)
+	}
+}
+
+func TestDedupThrottled(t *testing.T) {
+	m := message(t)
+	m.DedupWindow(time.Hour)
+	m.Throttle(time.Hour)
+	m.gate(url.Values{"message": {"a"}}, func() error { return nil })
+	if err := m.gate(url.Values{"message": {"b"}}, func() error { return nil }); err != ErrThrottled {
+		t.Fatalf("second message returned %v, want ErrThrottled", err)
+	}
+	m.ResetThrottle()
+	if err := m.gate(url.Values{"message": {"b"}}, func() error { return nil }); err != nil {
+		t.Errorf("throttled message was remembered as sent, err=%v", err)
+	}
+}
+
+func TestContentKey(t *testing.T) {
+	m := message(t)
+	// fixed expectation, the key must stay stable across versions
+	if got := m.ContentKey("disk", "full"); got != "8a512eb21275aab1" {
+		t.Errorf("content key=%s changed", got)
+	}
+	if m.ContentKey("disk", "full") == m.ContentKey("disk full", "") {
+		t.Errorf("title and message boundary not part of content key")
+	}
+	if len(m.ContentKey("", "")) != 16 {
+		t.Errorf("content key %q not 16 hex digits", m.ContentKey("", ""))
+	}
+}
+
+func TestDedupRetryFailed(t *testing.T) {
+	m := message(t)
+	m.DedupWindow(time.Hour)
+	v := url.Values{"message": {"a"}}
+	if err := m.gate(v, func() error { return errors.New("down") }); err == nil {
+		t.Fatal("failed send returned no error")
+	}
+	if err := m.gate(v, func() error { return nil }); err != nil {
+		t.Fatalf("retry after failed send returned %v", err)
+	}
+	if err := m.gate(v, func() error { return nil }); err != ErrDuplicate {
+		t.Errorf("message after successful retry returned %v, want ErrDuplicate", err)
+	}
+}
+
+func TestDedupConcurrent(t *testing.T) {
+	m := message(t)
+	m.DedupWindow(time.Hour)
+	v := url.Values{"message": {"a"}}
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go m.gateAsync(v, func(done func(error)) error {
+		close(started)
+		go func() { <-release; done(nil) }()
+		return nil
+	})
+	<-started
+	if err := m.gate(v, func() error { return nil }); err != ErrDuplicate {
+		t.Errorf("message while duplicate in flight returned %v, want ErrDuplicate", err)
+	}
+	close(release)
+}
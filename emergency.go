@@ -0,0 +1,181 @@
+package pushover
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Status of an emergency priority message, as returned by the receipts api
+type ReceiptStatus struct {
+	Receipt              string
+	Acknowledged         bool
+	AcknowledgedAt       time.Time
+	AcknowledgedBy       string // user key of the receiver who acknowledged
+	AcknowledgedByDevice string
+	LastDeliveredAt      time.Time
+	Expired              bool
+	ExpiresAt            time.Time
+}
+
+// Receipt status as sent by the api, flags are 0/1 and times are unix seconds
+type receiptResponse struct {
+	Acknowledged         int    `json:"acknowledged"`
+	AcknowledgedAt       int64  `json:"acknowledged_at"`
+	AcknowledgedBy       string `json:"acknowledged_by"`
+	AcknowledgedByDevice string `json:"acknowledged_by_device"`
+	LastDeliveredAt      int64  `json:"last_delivered_at"`
+	Expired              int    `json:"expired"`
+	ExpiresAt            int64  `json:"expires_at"`
+}
+
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// Retrieve the status of an emergency message sent by this message's application.
+func (m *Message) Receipt(ctx context.Context, receipt string) (ReceiptStatus, error) {
+	var r receiptResponse
+	path := "/receipts/" + url.PathEscape(receipt) + ".json?" + url.Values{"token": {m.app}}.Encode()
+	if _, err := m.p.call(ctx, http.MethodGet, path, "", nil, &r); err != nil {
+		return ReceiptStatus{Receipt: receipt}, err
+	}
+	return ReceiptStatus{
+		Receipt:              receipt,
+		Acknowledged:         r.Acknowledged == 1,
+		AcknowledgedAt:       unixTime(r.AcknowledgedAt),
+		AcknowledgedBy:       r.AcknowledgedBy,
+		AcknowledgedByDevice: r.AcknowledgedByDevice,
+		LastDeliveredAt:      unixTime(r.LastDeliveredAt),
+		Expired:              r.Expired == 1,
+		ExpiresAt:            unixTime(r.ExpiresAt),
+	}, nil
+}
+
+// Stop pushover from repeating an emergency message sent by this message's application
+// before it is acknowledged or expires.
+func (m *Message) CancelReceipt(ctx context.Context, receipt string) error {
+	_, err := m.p.postForm(ctx, "/receipts/"+url.PathEscape(receipt)+"/cancel.json", url.Values{"token": {m.app}}, nil)
+	return err
+}
+
+// Receipt polling interval used for a zero pollInterval, the fastest poll rate pushover
+// allows. Replaced by tests.
+var defaultPollInterval = 5 * time.Second
+
+// Send an emergency priority message and wait until it is acknowledged or expires.
+// Pushover repeats the notification every retry interval until it is acknowledged
+// or expire has passed. The receipt is polled every pollInterval, pushover asks to
+// poll no more often than every 5 seconds, which is used if pollInterval is not positive.
+// Zero retry or expire use the defaults, other values must be within the api limits,
+// see WithRetry().
+//
+// Check Acknowledged and Expired of the returned status. If the context is done first,
+// the last polled status is returned with the context error, the emergency message
+// keeps being repeated by pushover then.
+func (m *Message) SendEmergencyAndAwaitAck(ctx context.Context, title, message string, retry, expire, pollInterval time.Duration) (ReceiptStatus, error) {
+	if err := checkEmergency(retry, expire); err != nil {
+		return ReceiptStatus{}, err
+	}
+	v := m.values(title, message)
+	setEmergency(v, retry, expire)
+
+	var sent struct {
+		Receipt string `json:"receipt"`
+	}
+	if err := m.sendWait(ctx, v, nil, &sent); err != nil {
+		return ReceiptStatus{}, err
+	}
+	if sent.Receipt == "" {
+		return ReceiptStatus{}, errors.New("pushover returned no receipt for emergency message")
+	}
+
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+	status := ReceiptStatus{Receipt: sent.Receipt}
+	tick := time.NewTicker(pollInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-tick.C:
+		}
+		s, err := m.Receipt(ctx, sent.Receipt)
+		if ctx.Err() != nil {
+			return status, ctx.Err()
+		}
+		if err != nil {
+			return status, err
+		}
+		status = s
+		if status.Acknowledged || status.Expired {
+			return status, nil
+		}
+	}
+}
+
+// Error returned when no step of an escalation acknowledged the emergency message
+var ErrNotAcknowledged = errors.New("pushover emergency message not acknowledged")
+
+// Error returned when running an escalation without steps
+var ErrNoEscalationSteps = errors.New("pushover escalation has no steps")
+
+// On-call escalation: an emergency message is sent to the receiver of the first step,
+// if it is not acknowledged within the step's timeout it is cancelled and sent to the
+// next step's receiver and so on.
+type Escalation struct {
+	Steps        []EscalationStep
+	PollInterval time.Duration // receipt polling interval, see SendEmergencyAndAwaitAck()
+}
+
+// Step of an escalation
+type EscalationStep struct {
+	Message       *Message
+	Timeout       time.Duration // wait for acknowledgement before escalating, zero waits until expire
+	Retry, Expire time.Duration // emergency parameters of the step's message, zero for defaults
+}
+
+// Run the escalation until a step acknowledges. Returns the acknowledged receipt status and
+// the index of the acknowledging step. If no step acknowledges, the status of the last step
+// is returned with ErrNotAcknowledged. A step that cannot be sent escalates immediately,
+// the emergency message of a step that times out is cancelled before escalating.
+// Cancelling the context stops the escalation at the current step.
+func (e Escalation) Run(ctx context.Context, title, message string) (ReceiptStatus, int, error) {
+	if len(e.Steps) == 0 {
+		return ReceiptStatus{}, -1, ErrNoEscalationSteps
+	}
+	var status ReceiptStatus
+	for i, step := range e.Steps {
+		timeout := step.Timeout
+		if timeout <= 0 {
+			timeout = step.Expire
+		}
+		if timeout <= 0 {
+			timeout = DefaultEmergencyExpire
+		}
+		stepCtx, cancel := context.WithTimeout(ctx, timeout)
+		s, err := step.Message.SendEmergencyAndAwaitAck(stepCtx, title, message, step.Retry, step.Expire, e.PollInterval)
+		cancel()
+		if ctx.Err() != nil {
+			return s, i, ctx.Err()
+		}
+		if err == nil && s.Acknowledged {
+			return s, i, nil
+		}
+		if s.Receipt != "" && !s.Expired && i < len(e.Steps)-1 {
+			// stop paging this step's receiver, a failed cancel must not stop the escalation
+			cancelCtx, cancel := timeoutContext(ctx, defaultTimeout)
+			step.Message.CancelReceipt(cancelCtx, s.Receipt)
+			cancel()
+		}
+		status = s
+	}
+	return status, len(e.Steps) - 1, ErrNotAcknowledged
+}
@@ -0,0 +1,155 @@
+package pushover
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Mock server for emergency messages, the receipt is acknowledged after ackAfter polls,
+// a negative ackAfter never acknowledges.
+func mockEmergency(t *testing.T, ackAfter int32) *atomic.Int32 {
+	var polls atomic.Int32
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/messages.json":
+			r.ParseForm()
+			if r.PostForm.Get("priority") != "2" || r.PostForm.Get("retry") != "60" || r.PostForm.Get("expire") != "3600" {
+				t.Errorf("emergency message posted %v", r.PostForm)
+			}
+			fmt.Fprintf(w, `{"status":1,"request":"req","receipt":"rcpt-%s"}`, r.PostForm.Get("user"))
+		case strings.HasPrefix(r.URL.Path, "/receipts/"):
+			if r.URL.Query().Get("token") != "app1" {
+				t.Errorf("receipt polled with token %q", r.URL.Query().Get("token"))
+			}
+			if n := polls.Add(1); ackAfter < 0 || n < ackAfter {
+				fmt.Fprint(w, `{"status":1,"acknowledged":0,"expired":0,"expires_at":1700003600}`)
+				return
+			}
+			fmt.Fprint(w, `{"status":1,"acknowledged":1,"acknowledged_at":1700000100,"acknowledged_by":"rec1","acknowledged_by_device":"phone","expired":0}`)
+		default:
+			t.Errorf("unexpected request for %s", r.URL.Path)
+		}
+	})
+	return &polls
+}
+
+func TestSendEmergencyAndAwaitAck(t *testing.T) {
+	polls := mockEmergency(t, 3)
+	m := message(t)
+	status, err := m.SendEmergencyAndAwaitAck(context.Background(), "Down", "db01", time.Minute, time.Hour, time.Millisecond)
+	if err != nil {
+		t.Fatalf("emergency message returned error: %s", err)
+	}
+	if !status.Acknowledged || status.AcknowledgedBy != "rec1" || status.AcknowledgedByDevice != "phone" || status.Receipt != "rcpt-rec1" {
+		t.Errorf("unexpected receipt status %+v", status)
+	}
+	if !status.AcknowledgedAt.Equal(time.Unix(1700000100, 0)) {
+		t.Errorf("acknowledged at %s", status.AcknowledgedAt)
+	}
+	if polls.Load() != 3 {
+		t.Errorf("receipt polled %d times, want 3", polls.Load())
+	}
+}
+
+func TestSendEmergencyAndAwaitAckDefaultPoll(t *testing.T) {
+	defer func(d time.Duration) { defaultPollInterval = d }(defaultPollInterval)
+	defaultPollInterval = time.Millisecond
+	mockEmergency(t, 2)
+	m := message(t)
+	status, err := m.SendEmergencyAndAwaitAck(context.Background(), "Down", "db01", time.Minute, time.Hour, 0)
+	if err != nil || !status.Acknowledged {
+		t.Errorf("emergency message with zero poll interval returned %+v, err=%v", status, err)
+	}
+}
+
+func TestSendEmergencyAndAwaitAckCancel(t *testing.T) {
+	mockEmergency(t, -1)
+	m := message(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	status, err := m.SendEmergencyAndAwaitAck(ctx, "Down", "db01", time.Minute, time.Hour, 5*time.Millisecond)
+	if err != context.DeadlineExceeded {
+		t.Errorf("cancelled wait returned %v, want deadline exceeded", err)
+	}
+	if status.Acknowledged || status.Receipt != "rcpt-rec1" || status.ExpiresAt.IsZero() {
+		t.Errorf("unexpected receipt status %+v", status)
+	}
+}
+
+func TestEscalation(t *testing.T) {
+	var acker atomic.Value
+	acker.Store("")
+	cancelled := make(chan string, 10)
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/cancel.json") {
+			r.ParseForm()
+			if r.PostForm.Get("token") != "app1" {
+				t.Errorf("receipt cancelled with token %q", r.PostForm.Get("token"))
+			}
+			cancelled <- strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/receipts/"), "/cancel.json")
+			fmt.Fprint(w, `{"status":1,"request":"req"}`)
+			return
+		}
+		if r.URL.Path == "/messages.json" {
+			r.ParseForm()
+			fmt.Fprintf(w, `{"status":1,"request":"req","receipt":"%s"}`, r.PostForm.Get("user"))
+			return
+		}
+		if strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/receipts/"), ".json") == acker.Load() {
+			fmt.Fprint(w, `{"status":1,"acknowledged":1}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":1,"acknowledged":0}`)
+	})
+	p := load(t)
+	first, second := p.MustMessage("a1", "r1"), p.MustMessage("a1", "work")
+	e := Escalation{PollInterval: time.Millisecond, Steps: []EscalationStep{
+		{Message: &first, Timeout: 20 * time.Millisecond, Retry: time.Minute, Expire: time.Hour},
+		{Message: &second, Timeout: 20 * time.Millisecond, Retry: time.Minute, Expire: time.Hour},
+	}}
+
+	acker.Store("rec3")
+	status, step, err := e.Run(context.Background(), "Down", "db01")
+	if err != nil || step != 1 || status.Receipt != "rec3" {
+		t.Errorf("escalation acknowledged by step %d, receipt %s, err=%v, want step 1", step, status.Receipt, err)
+	}
+	if len(cancelled) != 1 || <-cancelled != "rec1" {
+		t.Errorf("timed out step was not cancelled before escalating")
+	}
+
+	acker.Store("rec1")
+	if _, step, err := e.Run(context.Background(), "Down", "db01"); err != nil || step != 0 {
+		t.Errorf("escalation acknowledged by step %d, err=%v, want step 0", step, err)
+	}
+
+	acker.Store("nobody")
+	if _, _, err := e.Run(context.Background(), "Down", "db01"); err != ErrNotAcknowledged {
+		t.Errorf("unacknowledged escalation returned %v, want ErrNotAcknowledged", err)
+	}
+	if len(cancelled) != 1 || <-cancelled != "rec1" {
+		t.Errorf("cancelled other than the timed out first step")
+	}
+
+	// zero poll interval and timeout use the defaults instead of panicking or escalating at once
+	defer func(d time.Duration) { defaultPollInterval = d }(defaultPollInterval)
+	defaultPollInterval = time.Millisecond
+	acker.Store("rec1")
+	zero := Escalation{Steps: []EscalationStep{{Message: &first}, {Message: &second}}}
+	if _, step, err := zero.Run(context.Background(), "Down", "db01"); err != nil || step != 0 {
+		t.Errorf("escalation with zero values acknowledged by step %d, err=%v, want step 0", step, err)
+	}
+	if _, _, err := (Escalation{}).Run(context.Background(), "Down", "db01"); err != ErrNoEscalationSteps {
+		t.Errorf("escalation without steps returned %v, want ErrNoEscalationSteps", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, step, err := e.Run(ctx, "Down", "db01"); err != context.Canceled || step != 0 {
+		t.Errorf("cancelled escalation stopped at step %d with %v", step, err)
+	}
+}
@@ -0,0 +1,42 @@
+package pushover
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Send structured alert fields like host, service and value in background, rendered as
+// "key: value" lines sorted by key. Values are aligned, so they line up in columns with
+// WithExtra("monospace", "1"). Fields exceeding the api limit of 1024 characters are
+// left out with a note how many are missing. Errors are handled like in Send().
+func (m *Message) SendFields(title string, fields map[string]string) error {
+	return m.sendBackground(context.Background(), m.values(title, renderFields(fields)), nil)
+}
+
+// Render fields as aligned "key: value" lines within the api limit
+func renderFields(fields map[string]string) string {
+	keys := sortedNames(fields)
+	width := 0
+	for _, k := range keys {
+		width = max(width, utf8.RuneCountInString(k))
+	}
+	lines := make([]string, 0, len(keys))
+	n := 0
+	for i, k := range keys {
+		line := fmt.Sprintf("%-*s %s", width+1, k+":", fields[k])
+		// leave room for the note unless this is the last field
+		note := ""
+		if i < len(keys)-1 {
+			note = fmt.Sprintf("… %d more fields", len(keys)-i-1)
+		}
+		if n+utf8.RuneCountInString(line)+1+utf8.RuneCountInString(note) > maxMessage {
+			lines = append(lines, fmt.Sprintf("… %d more fields", len(keys)-i))
+			break
+		}
+		lines = append(lines, line)
+		n += utf8.RuneCountInString(line) + 1
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,33 @@
+package pushover
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRenderFields(t *testing.T) {
+	got := renderFields(map[string]string{"service": "nginx", "host": "web01", "value": "98%"})
+	want := "host:    web01\nservice: nginx\nvalue:   98%"
+	if got != want {
+		t.Errorf("rendered\n%s\nwant\n%s", got, want)
+	}
+
+	fields := map[string]string{}
+	for i := 0; i < 100; i++ {
+		fields[fmt.Sprintf("key%02d", i)] = strings.Repeat("x", 40)
+	}
+	got = renderFields(fields)
+	lines := strings.Split(got, "\n")
+	if utf8.RuneCountInString(got) > maxMessage {
+		t.Errorf("rendered %d characters, want at most %d", utf8.RuneCountInString(got), maxMessage)
+	}
+	kept := len(lines) - 1
+	if last := lines[kept]; last != fmt.Sprintf("… %d more fields", 100-kept) {
+		t.Errorf("last line %q, want note about %d missing fields", last, 100-kept)
+	}
+	if !strings.HasPrefix(lines[0], "key00:") || !strings.HasPrefix(lines[kept-1], fmt.Sprintf("key%02d:", kept-1)) {
+		t.Errorf("fields not in order: %q ... %q", lines[0], lines[kept-1])
+	}
+}
@@ -0,0 +1,85 @@
+package pushover
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Pushover delivery group, managed through the groups api with an application token.
+// Used e.g. to automate on-call rotations by enabling and disabling group members.
+type Group struct {
+	App string // application token
+	Key string // group key
+
+	p *Pushover // retry settings, nil for groups not created by Pushover.Group()
+}
+
+// Create a Group for given Application and Receiver keys, the receiver must be a group key.
+func (p *Pushover) Group(app, group string) (Group, error) {
+	a, aok := p.App[app]
+	r, rok := p.Rec[group]
+	g := Group{App: a, Key: r.Key, p: p}
+	if !aok {
+		return g, fmt.Errorf("invalid pushover application: %s", app)
+	}
+	if !rok {
+		return g, fmt.Errorf("invalid pushover receiver: %s", group)
+	}
+	return g, nil
+}
+
+// Name and members of a group as returned by the groups api
+type GroupInfo struct {
+	Name  string      `json:"name"`
+	Users []GroupUser `json:"users"`
+}
+
+// Member of a group, device is empty if the user receives on all devices
+type GroupUser struct {
+	User     string `json:"user"`
+	Device   string `json:"device"`
+	Memo     string `json:"memo"`
+	Disabled bool   `json:"disabled"`
+}
+
+// Retrieve name and members of the group, e.g. to audit an escalation group.
+func (g Group) Info() (GroupInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	var info GroupInfo
+	path := "/groups/" + url.PathEscape(g.Key) + ".json?" + url.Values{"token": {g.App}}.Encode()
+	_, err := g.p.call(ctx, http.MethodGet, path, "", nil, &info)
+	return info, err
+}
+
+// Retrieve name and members of a group for given Application and Receiver keys,
+// the receiver must be a group key.
+func (p *Pushover) GroupInfo(app, group string) (GroupInfo, error) {
+	g, err := p.Group(app, group)
+	if err != nil {
+		return GroupInfo{}, err
+	}
+	return g.Info()
+}
+
+// Add a user to the group
+func (g Group) AddUser(user string) error { return g.user("add_user", user) }
+
+// Remove a user from the group
+func (g Group) RemoveUser(user string) error { return g.user("remove_user", user) }
+
+// Temporarily stop sending group messages to a user
+func (g Group) DisableUser(user string) error { return g.user("disable_user", user) }
+
+// Resume sending group messages to a previously disabled user
+func (g Group) EnableUser(user string) error { return g.user("enable_user", user) }
+
+func (g Group) user(action, user string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	path := "/groups/" + url.PathEscape(g.Key) + "/" + action + ".json"
+	_, err := g.p.postForm(ctx, path, url.Values{"token": {g.App}, "user": {user}}, nil)
+	return err
+}
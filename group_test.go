@@ -0,0 +1,83 @@
+package pushover
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestGroupUsers(t *testing.T) {
+	var path, token, user string
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		path, token, user = r.URL.Path, r.PostForm.Get("token"), r.PostForm.Get("user")
+		if user == "unknown" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"user":"invalid","errors":["user key is invalid"],"status":0,"request":"req-1"}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	p := load(t)
+	g, err := p.Group("a1", "r2")
+	if err != nil {
+		t.Fatalf("cannot create group: %s", err)
+	}
+
+	for _, tc := range []struct {
+		fn   func(string) error
+		path string
+	}{
+		{g.AddUser, "/groups/rec1/add_user.json"},
+		{g.RemoveUser, "/groups/rec1/remove_user.json"},
+		{g.DisableUser, "/groups/rec1/disable_user.json"},
+		{g.EnableUser, "/groups/rec1/enable_user.json"},
+	} {
+		if err := tc.fn("u1"); err != nil {
+			t.Errorf("%s returned error: %s", tc.path, err)
+		}
+		if path != tc.path || token != "app1" || user != "u1" {
+			t.Errorf("posted to %s token=%s user=%s, want %s", path, token, user, tc.path)
+		}
+	}
+
+	var apiErr *APIError
+	if err := g.AddUser("unknown"); !errors.As(err, &apiErr) {
+		t.Fatalf("adding unknown user returned %v, want APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest || apiErr.Request != "req-1" || len(apiErr.Errors) != 1 {
+		t.Errorf("unexpected api error %#v", apiErr)
+	}
+
+	if _, err := p.Group("a1", "missing"); err == nil {
+		t.Errorf("created group for unknown receiver without error")
+	}
+}
+
+func TestGroupInfo(t *testing.T) {
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/groups/rec1.json" || r.URL.Query().Get("token") != "app1" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		fmt.Fprint(w, `{"name":"On Call","users":[
+			{"user":"u1","device":null,"memo":"alice","disabled":false},
+			{"user":"u2","device":"phone","memo":"","disabled":true}
+		],"status":1,"request":"req"}`)
+	})
+	p := load(t)
+	info, err := p.GroupInfo("a1", "r2")
+	if err != nil {
+		t.Fatalf("cannot get group info: %s", err)
+	}
+	want := GroupInfo{Name: "On Call", Users: []GroupUser{
+		{User: "u1", Memo: "alice"},
+		{User: "u2", Device: "phone", Disabled: true},
+	}}
+	if fmt.Sprint(info) != fmt.Sprint(want) {
+		t.Errorf("group info=%+v, want %+v", info, want)
+	}
+	if _, err := p.GroupInfo("a3", "r2"); err == nil {
+		t.Errorf("got group info for unknown application without error")
+	}
+}
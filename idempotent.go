@@ -0,0 +1,84 @@
+package pushover
+
+import (
+	"context"
+	"time"
+)
+
+// Number of idempotency keys remembered by SendIdempotent(), the least recently used
+// key is dropped first
+const maxIdempotent = 256
+
+// Time an idempotency key is remembered if Pushover.IdempotencyTTL is zero
+const defaultIdempotencyTTL = 10 * time.Minute
+
+// Send of an idempotency key, done is closed once err is set
+type idempotent struct {
+	sent, used time.Time
+	done       chan struct{}
+	err        error
+}
+
+// Send a message and wait for the result, unless a message with the same caller provided
+// key has been sent successfully within Pushover.IdempotencyTTL. Repeated calls are no-ops
+// returning nil then, which prevents duplicate pages when the same event fires again from
+// retried upstream logic. A call for a key still being sent waits for that send and
+// returns its result. Failed sends are forgotten, so a retry can still deliver. Keys are
+// shared by all messages of the Pushover.
+func (m *Message) SendIdempotent(key, title, message string) error {
+	send := func() error {
+		ctx, cancel := timeoutContext(context.Background(), defaultTimeout)
+		defer cancel()
+		return m.sendWait(ctx, m.values(title, message), nil, nil)
+	}
+	if m.p == nil {
+		return send()
+	}
+	ttl := m.p.IdempotencyTTL
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	now := m.now()
+	st := m.p.state()
+	st.mu.Lock()
+	if e, ok := st.idempotent[key]; ok && now.Sub(e.sent) < ttl {
+		e.used = now
+		st.mu.Unlock()
+		<-e.done
+		return e.err
+	}
+	e := &idempotent{sent: now, used: now, done: make(chan struct{})}
+	st.rememberIdempotent(key, e, now, ttl)
+	st.mu.Unlock()
+
+	e.err = send()
+	if e.err != nil {
+		st.mu.Lock()
+		if st.idempotent[key] == e {
+			delete(st.idempotent, key)
+		}
+		st.mu.Unlock()
+	}
+	close(e.done)
+	return e.err
+}
+
+// Remember key, dropping expired and, if still full, the least recently used keys.
+// Called with st.mu held.
+func (st *state) rememberIdempotent(key string, e *idempotent, now time.Time, ttl time.Duration) {
+	if len(st.idempotent) >= maxIdempotent {
+		var lru string
+		var lruAt time.Time
+		for k, old := range st.idempotent {
+			if now.Sub(old.sent) >= ttl {
+				delete(st.idempotent, k)
+			} else if lruAt.IsZero() || old.used.Before(lruAt) {
+				lru, lruAt = k, old.used
+			}
+		}
+		if len(st.idempotent) >= maxIdempotent {
+			delete(st.idempotent, lru)
+		}
+	}
+	st.idempotent[key] = e
+}
@@ -0,0 +1,81 @@
+package pushover
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSendIdempotent(t *testing.T) {
+	var posts atomic.Int32
+	var fail atomic.Bool
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		posts.Add(1)
+		if fail.Load() {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":0,"errors":["message is invalid"]}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	p := load(t)
+	p.IdempotencyTTL = time.Minute
+	clock := &fakeClock{t: time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)}
+	p.clock = clock
+	m := p.MustMessage("a1", "r1")
+	other := p.MustMessage("a1", "r2")
+
+	send := func(m *Message, key string, wantPosts int32) {
+		t.Helper()
+		if err := m.SendIdempotent(key, "event", key); err != nil {
+			t.Fatalf("send %s: %s", key, err)
+		}
+		if posts.Load() != wantPosts {
+			t.Errorf("after send %s posted %d messages, want %d", key, posts.Load(), wantPosts)
+		}
+	}
+	send(&m, "evt-1", 1)
+	send(&m, "evt-1", 1)     // hit
+	send(&other, "evt-1", 1) // keys are shared by all messages
+	send(&m, "evt-2", 2)     // miss
+	clock.Advance(time.Minute)
+	send(&m, "evt-1", 3) // expired
+
+	// failed sends are not remembered
+	fail.Store(true)
+	if err := m.SendIdempotent("evt-3", "event", "evt-3"); err == nil {
+		t.Fatal("rejected send returned no error")
+	}
+	fail.Store(false)
+	send(&m, "evt-3", 5)
+
+	// the cache is bounded
+	for i := 0; i < maxIdempotent+10; i++ {
+		m.SendIdempotent(fmt.Sprint("bulk-", i), "event", "bulk")
+	}
+	if n := len(p.state().idempotent); n > maxIdempotent {
+		t.Errorf("remembered %d keys, want at most %d", n, maxIdempotent)
+	}
+}
+
+func TestIdempotencyTTLConfig(t *testing.T) {
+	for config, want := range map[string]time.Duration{
+		`{"idempotency_ttl": 90}`:      90 * time.Second,
+		`{"idempotency_ttl": "1h30m"}`: 90 * time.Minute,
+		`{}`:                           0,
+	} {
+		p, err := LoadReader(strings.NewReader(config))
+		if err != nil || p.IdempotencyTTL != want {
+			t.Errorf("config %s loaded ttl %s, err=%v, want %s", config, p.IdempotencyTTL, err, want)
+		}
+	}
+	for _, config := range []string{`{"idempotency_ttl": "soon"}`, `{"idempotency_ttl": true}`} {
+		if _, err := LoadReader(strings.NewReader(config)); !errors.Is(err, ErrConfigInvalid) {
+			t.Errorf("config %s returned %v, want ErrConfigInvalid", config, err)
+		}
+	}
+}
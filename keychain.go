@@ -0,0 +1,6 @@
+package pushover
+
+import "errors"
+
+// Error returned by LoadKeychain() on systems without a keychain
+var ErrKeychainUnsupported = errors.New("pushover keychain only supported on macOS")
@@ -0,0 +1,40 @@
+//go:build darwin
+
+package pushover
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// Load application and receiver keys from the macOS keychain, so desktop apps don't need
+// to keep their tokens in a plaintext file. The whole json config is stored as password of
+// a generic keychain item for service, e.g.
+//
+//	security add-generic-password -s pushover -a pushover -w "$(cat pushover.json)"
+//
+// The item is read with the security command line tool to stay dependency free. Other
+// systems return ErrKeychainUnsupported.
+func LoadKeychain(service string) (Pushover, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", service, "-w").Output()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == 44 { // errSecItemNotFound
+		return Pushover{}, fmt.Errorf("%w: no keychain item for service %s", ErrConfigNotFound, service)
+	}
+	if err != nil {
+		return Pushover{}, fmt.Errorf("cannot read keychain: %w", err)
+	}
+	return unmarshal(keychainPassword(out), loadOptions{})
+}
+
+// Passwords with non-printable characters like newlines are printed hex encoded
+func keychainPassword(out []byte) []byte {
+	out = bytes.TrimSpace(out)
+	if b, err := hex.DecodeString(string(out)); err == nil && bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		return b
+	}
+	return out
+}
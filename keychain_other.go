@@ -0,0 +1,8 @@
+//go:build !darwin
+
+package pushover
+
+// Load application and receiver keys from the macOS keychain, only supported on macOS.
+func LoadKeychain(service string) (Pushover, error) {
+	return Pushover{}, ErrKeychainUnsupported
+}
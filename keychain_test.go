@@ -0,0 +1,14 @@
+//go:build !darwin
+
+package pushover
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLoadKeychainUnsupported(t *testing.T) {
+	if _, err := LoadKeychain("pushover"); !errors.Is(err, ErrKeychainUnsupported) {
+		t.Errorf("keychain on unsupported system returned %v, want ErrKeychainUnsupported", err)
+	}
+}
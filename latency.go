@@ -0,0 +1,82 @@
+package pushover
+
+import "time"
+
+// Number of sends averaged by Latency()
+const latencySamples = 10
+
+// Round trip times of the last sends to a receiver
+type latency struct {
+	samples [latencySamples]time.Duration
+	n       int // number of samples recorded, the next one is stored at n % latencySamples
+}
+
+func (l *latency) add(d time.Duration) {
+	l.samples[l.n%latencySamples] = d
+	l.n++
+}
+
+func (l *latency) last() time.Duration { return l.samples[(l.n-1)%latencySamples] }
+
+func (l *latency) average() time.Duration {
+	n := l.n
+	if n > latencySamples {
+		n = latencySamples
+	}
+	var sum time.Duration
+	for _, d := range l.samples[:n] {
+		sum += d
+	}
+	return sum / time.Duration(n)
+}
+
+// Average round trip time of the last 10 sends to a receiver of the config that got a
+// response, from request start until the response is read. Only the last attempt of a
+// retried send counts, waits between retries are left out. For SendRaw() the time until
+// the response headers arrive counts. Zero if nothing has been sent to the receiver yet.
+// Helps to detect a slow api or target.
+func (p *Pushover) Latency(receiver string) time.Duration {
+	return p.latency(receiver, (*latency).average)
+}
+
+// Round trip time of the last send to a receiver that got a response, see Latency()
+func (p *Pushover) LastLatency(receiver string) time.Duration {
+	return p.latency(receiver, (*latency).last)
+}
+
+func (p *Pushover) latency(receiver string, f func(*latency) time.Duration) time.Duration {
+	st := p.state()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	l := st.latency[receiver]
+	if l == nil {
+		return 0
+	}
+	return f(l)
+}
+
+// Record the round trip time of a send for each receiver of the message
+func (m *Message) observeLatency(d time.Duration) {
+	if m.batch == nil {
+		m.p.observeLatency(m.recName, d)
+	}
+	for _, rec := range m.batch {
+		m.p.observeLatency(rec, d)
+	}
+}
+
+// Record the round trip time of a send to a receiver
+func (p *Pushover) observeLatency(receiver string, d time.Duration) {
+	if p == nil || receiver == "" {
+		return
+	}
+	st := p.state()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	l := st.latency[receiver]
+	if l == nil {
+		l = &latency{}
+		st.latency[receiver] = l
+	}
+	l.add(d)
+}
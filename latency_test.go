@@ -0,0 +1,94 @@
+package pushover
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLatency(t *testing.T) {
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	p := load(t)
+	if d := p.Latency("r1"); d != 0 {
+		t.Errorf("latency %s before any send, want 0", d)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m := p.MustMessage("a1", "r1")
+			if err := m.SendAndWait("title", "message", time.Second); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if d := p.Latency("r1"); d < 20*time.Millisecond || d > time.Second {
+		t.Errorf("latency %s, want about 20ms", d)
+	}
+	if d := p.Latency("r2"); d != 0 {
+		t.Errorf("latency %s for receiver without sends, want 0", d)
+	}
+}
+
+func TestLatencyRetries(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = 200 * time.Millisecond
+	var calls atomic.Int32
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	p := load(t)
+	p.Retries = 1
+	m := p.MustMessage("a1", "r1")
+	if err := m.SendAndWait("title", "message", time.Second); err != nil || calls.Load() != 2 {
+		t.Fatalf("retried send made %d calls, err=%v", calls.Load(), err)
+	}
+	if d := p.LastLatency("r1"); d <= 0 || d >= retryDelay {
+		t.Errorf("last latency %s includes the retry wait", d)
+	}
+
+	resp, err := m.SendRaw(context.Background(), "title", "message")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if d := p.Latency("r1"); d <= 0 || d >= retryDelay {
+		t.Errorf("latency %s after raw send", d)
+	}
+	st := p.state()
+	st.mu.Lock()
+	n := st.latency["r1"].n
+	st.mu.Unlock()
+	if n != 2 {
+		t.Errorf("recorded %d latency samples, want 2 including the raw send", n)
+	}
+}
+
+func TestLatencyAverage(t *testing.T) {
+	var l latency
+	for i := 1; i <= 3; i++ {
+		l.add(time.Duration(i) * time.Millisecond)
+	}
+	if d := l.average(); d != 2*time.Millisecond {
+		t.Errorf("average of 1, 2 and 3ms is %s", d)
+	}
+	for i := 0; i < latencySamples; i++ {
+		l.add(5 * time.Millisecond)
+	}
+	if d := l.average(); d != 5*time.Millisecond {
+		t.Errorf("average keeps old samples: %s", d)
+	}
+}
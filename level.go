@@ -0,0 +1,59 @@
+package pushover
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"strconv"
+)
+
+// Log level for failures that need immediate attention, above slog.LevelError
+const LevelCritical = slog.LevelError + 4
+
+// Priority of log levels from the given level up, see PriorityForLevel()
+type LevelPriority struct {
+	Level    slog.Level
+	Priority Priority
+}
+
+// Mapping of log levels to priorities, ordered from the highest level down. Tune it at
+// program start, before messages are sent.
+var LevelPriorities = []LevelPriority{
+	{LevelCritical, PriorityEmergency},
+	{slog.LevelError, PriorityHigh},
+	{slog.LevelWarn, PriorityNormal},
+	{slog.LevelInfo, PriorityLow},
+}
+
+// Priority for a log level: the priority of the first entry of LevelPriorities the level
+// reaches, PriorityLowest for levels below all entries like slog.LevelDebug.
+func PriorityForLevel(level slog.Level) Priority {
+	for _, lp := range LevelPriorities {
+		if level >= lp.Level {
+			return lp.Priority
+		}
+	}
+	return PriorityLowest
+}
+
+// Send a message in background with the priority of a log level, overriding the message's
+// priority. Errors are handled like in Send().
+func (m *Message) SendLevel(level slog.Level, title, message string) error {
+	return m.sendBackground(context.Background(), m.levelValues(level, title, message), nil)
+}
+
+// Message values with the priority of a log level
+func (m *Message) levelValues(level slog.Level, title, message string) url.Values {
+	v := m.values(title, message)
+	v.Del("retry")
+	v.Del("expire")
+	switch priority := PriorityForLevel(level); priority {
+	case PriorityNormal:
+		v.Del("priority")
+	case PriorityEmergency:
+		setEmergency(v, m.retry, m.expire)
+	default:
+		v.Set("priority", strconv.Itoa(int(priority)))
+	}
+	return v
+}
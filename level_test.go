@@ -0,0 +1,63 @@
+package pushover
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestPriorityForLevel(t *testing.T) {
+	for _, tc := range []struct {
+		level slog.Level
+		want  Priority
+	}{
+		{slog.LevelDebug, PriorityLowest},
+		{slog.LevelInfo - 1, PriorityLowest},
+		{slog.LevelInfo, PriorityLow},
+		{slog.LevelWarn - 1, PriorityLow},
+		{slog.LevelWarn, PriorityNormal},
+		{slog.LevelError - 1, PriorityNormal},
+		{slog.LevelError, PriorityHigh},
+		{LevelCritical - 1, PriorityHigh},
+		{LevelCritical, PriorityEmergency},
+		{LevelCritical + 4, PriorityEmergency},
+	} {
+		if got := PriorityForLevel(tc.level); got != tc.want {
+			t.Errorf("PriorityForLevel(%s)=%d, want %d", tc.level, got, tc.want)
+		}
+	}
+
+	defer func(lp []LevelPriority) { LevelPriorities = lp }(LevelPriorities)
+	LevelPriorities = []LevelPriority{{slog.LevelWarn, PriorityEmergency}}
+	if PriorityForLevel(slog.LevelWarn) != PriorityEmergency || PriorityForLevel(slog.LevelInfo) != PriorityLowest {
+		t.Errorf("overridden mapping not used")
+	}
+}
+
+func TestSendLevel(t *testing.T) {
+	posted := make(chan url.Values, 1)
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		posted <- r.PostForm
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	m := message(t)
+	m.Set(WithPriority(PriorityLowest))
+	for _, tc := range []struct {
+		level           slog.Level
+		priority, retry string
+	}{
+		{LevelCritical, "2", "60"},
+		{slog.LevelError, "1", ""},
+		{slog.LevelWarn, "", ""},
+	} {
+		if err := m.SendLevel(tc.level, "db", "down"); err != nil {
+			t.Fatal(err)
+		}
+		if v := <-posted; v.Get("priority") != tc.priority || v.Get("retry") != tc.retry {
+			t.Errorf("level %s posted priority %q, retry %q", tc.level, v.Get("priority"), v.Get("retry"))
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Package license assigns pushover licenses to users through the licensing api, for
+// applications that distribute pushover to their end users.
+// (c) fpunkt@icloud.com
+package license
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/fpunkt/pushover"
+)
+
+// Base url of the pushover api, replaced by tests
+var apiURL = "https://api.pushover.net/1"
+
+// Assign a license to the pushover account with given email, creating the account if
+// necessary. The application token must own license credits, the remaining number of
+// credits is returned. Errors reported by the api are returned as *pushover.APIError.
+func Assign(appToken, email string) (int, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.PostForm(apiURL+"/licenses/assign.json", url.Values{
+		"token": {appToken},
+		"email": {email},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var r struct {
+		Status  int      `json:"status"`
+		Request string   `json:"request"`
+		Errors  []string `json:"errors"`
+		Credits int      `json:"credits"`
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&r)
+	if err != nil || r.Status != 1 {
+		return 0, &pushover.APIError{StatusCode: resp.StatusCode, Request: r.Request, Errors: r.Errors}
+	}
+	return r.Credits, nil
+}
@@ -0,0 +1,38 @@
+package license
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fpunkt/pushover"
+)
+
+func TestAssign(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.URL.Path != "/licenses/assign.json" || r.PostForm.Get("token") != "app1" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.PostForm)
+		}
+		if r.PostForm.Get("email") == "nobody@example.com" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":0,"errors":["no license credits remaining"],"request":"req-2"}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":1,"request":"req-1","credits":4}`)
+	}))
+	defer srv.Close()
+	apiURL = srv.URL
+
+	credits, err := Assign("app1", "user@example.com")
+	if err != nil || credits != 4 {
+		t.Errorf("assign returned credits=%d, err=%v, want 4 credits", credits, err)
+	}
+
+	var apiErr *pushover.APIError
+	if _, err := Assign("app1", "nobody@example.com"); !errors.As(err, &apiErr) || apiErr.Request != "req-2" {
+		t.Errorf("failed assign returned %v, want APIError", err)
+	}
+}
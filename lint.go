@@ -0,0 +1,76 @@
+package pushover
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Length of pushover application tokens and user/group keys
+const keyLength = 30
+
+// Check the config for likely mistakes and return human readable warnings, sorted. Unlike
+// HasApp() and HasRec() this is non-fatal diagnostics, e.g. for a CLI --check flag:
+// empty or malformed keys, the same key under different names, receiver names also used
+// as application names and application tokens used as receiver keys.
+func (p *Pushover) Lint() []string {
+	var warnings []string
+	warn := func(format string, args ...any) { warnings = append(warnings, fmt.Sprintf(format, args...)) }
+
+	apps := map[string][]string{} // app names by token
+	for name, token := range p.App {
+		if w := lintKey(token); w != "" {
+			warn("app %s: %s", name, w)
+		}
+		apps[token] = append(apps[token], name)
+	}
+	for _, names := range apps {
+		sort.Strings(names)
+	}
+	recs := map[string][]string{} // receiver names by key
+	for name, r := range p.Rec {
+		if w := lintKey(r.Key); w != "" {
+			warn("rec %s: %s", name, w)
+		}
+		recs[r.Key] = append(recs[r.Key], name)
+		if _, ok := p.App[name]; ok {
+			warn("rec %s: also used as app name", name)
+		}
+		if names, ok := apps[r.Key]; ok && r.Key != "" {
+			warn("rec %s: key is the token of app %s", name, names[0])
+		}
+	}
+	for token, names := range apps {
+		if len(names) > 1 && token != "" {
+			warn("apps %v: same token", names)
+		}
+	}
+	for key, names := range recs {
+		if len(names) > 1 && key != "" {
+			sort.Strings(names)
+			warn("recs %v: same key", names)
+		}
+	}
+	for name, pr := range p.Profiles {
+		if pr.Priority < PriorityLowest || pr.Priority > PriorityEmergency {
+			warn("profile %s: invalid priority %d", name, pr.Priority)
+		}
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+// Describe what is wrong with a token or key, empty if it looks valid
+func lintKey(key string) string {
+	if key == "" {
+		return "empty key"
+	}
+	if len(key) != keyLength {
+		return fmt.Sprintf("key has %d characters, want %d", len(key), keyLength)
+	}
+	for _, c := range key {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return fmt.Sprintf("key contains invalid character %q", c)
+		}
+	}
+	return ""
+}
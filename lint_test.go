@@ -0,0 +1,40 @@
+package pushover
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	const (
+		app  = "azGDORePK8gMaC0QOYAMyEEuzJnyUi"
+		user = "uQiRzpo4DXghDmr9QzzfQu27cmVRsG"
+	)
+	p, err := LoadReader(strings.NewReader(`{
+		"app": {"home": "` + app + `", "copy": "` + app + `", "short": "abc", "ops": "` + user + `"},
+		"rec": {"me": "` + user + `", "empty": "", "odd": "uQiRzpo4DXghDmr9QzzfQu27cmVR-G", "home": "` + app + `"},
+		"profiles": {"loud": {"priority": 3}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"app short: key has 3 characters, want 30",
+		"apps [copy home]: same token",
+		"profile loud: invalid priority 3",
+		"rec empty: empty key",
+		"rec home: also used as app name",
+		"rec home: key is the token of app copy",
+		"rec me: key is the token of app ops",
+		"rec odd: key contains invalid character '-'",
+	}
+	if got := p.Lint(); !reflect.DeepEqual(got, want) {
+		t.Errorf("lint returned\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	clean := Pushover{App: map[string]string{"home": app}, Rec: map[string]Receiver{"me": {Key: user}}}
+	if got := clean.Lint(); len(got) != 0 {
+		t.Errorf("clean config has warnings %v", got)
+	}
+}
@@ -0,0 +1,93 @@
+package pushover
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"net/http"
+)
+
+// Option to configure loading a config, see Load()
+type LoadOption func(*loadOptions)
+
+type loadOptions struct {
+	strictPermissions bool
+	requireReceivers  bool
+	client            *http.Client
+	transport         http.RoundTripper
+	dial              func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+func newLoadOptions(opts []LoadOption) loadOptions {
+	var o loadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Error returned by Load() with StrictPermissions for config files readable by others
+var ErrInsecurePermissions = errors.New("pushover config readable by group or others")
+
+// Refuse to load config files that are readable or writable by group or others, like ssh
+// does for private keys, since the config contains secrets. Only enforced on unix systems
+// and by Load(), other loaders have no file permissions to check.
+func StrictPermissions() LoadOption {
+	return func(o *loadOptions) { o.strictPermissions = true }
+}
+
+// Check the permissions of a config file as requested by the options
+func (o loadOptions) checkFile(info fs.FileInfo) error {
+	if o.strictPermissions && insecure(info.Mode()) {
+		return ErrInsecurePermissions
+	}
+	return nil
+}
+
+// Refuse to load configs without receivers, which can never deliver a message. Configs
+// with only applications are fine otherwise, e.g. for the groups or licensing api.
+func RequireReceivers() LoadOption {
+	return func(o *loadOptions) { o.requireReceivers = true }
+}
+
+// Check a loaded config as requested by the options
+func (o loadOptions) checkConfig(p *Pushover) error {
+	if o.requireReceivers && len(p.Rec) == 0 {
+		return errors.New("no receivers configured")
+	}
+	return nil
+}
+
+// Make api calls with the given client, e.g. one with custom TLS settings or
+// instrumentation. Takes precedence over WithRoundTripper(). Close() closes idle
+// connections of the client, too.
+func WithHTTPClient(client *http.Client) LoadOption {
+	return func(o *loadOptions) { o.client = client }
+}
+
+// Make api calls with a client using the given transport, the minimal hook for recording
+// and replaying api calls in tests or adding middleware. Ignored with WithHTTPClient().
+func WithRoundTripper(rt http.RoundTripper) LoadOption {
+	return func(o *loadOptions) { o.transport = rt }
+}
+
+// Open connections of api calls with the given function, e.g. DialContext of a
+// net.Dialer with a custom Resolver to pin DNS, or one dialing "tcp4" to force IPv4. The
+// default transport is used otherwise. Mutually exclusive with WithHTTPClient() and
+// WithRoundTripper(), which bring their own transport and take precedence.
+func WithDialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) LoadOption {
+	return func(o *loadOptions) { o.dial = dial }
+}
+
+// Apply the options to a loaded config
+func (o loadOptions) configure(p *Pushover) {
+	p.httpClient = o.client
+	if p.httpClient == nil && o.transport != nil {
+		p.httpClient = &http.Client{Transport: o.transport}
+	}
+	if p.httpClient == nil && o.dial != nil {
+		p.httpClient = newClient()
+		p.httpClient.Transport.(*http.Transport).DialContext = o.dial
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package pushover
+
+import "io/fs"
+
+// File permissions do not map to unix modes, nothing is enforced
+func insecure(mode fs.FileMode) bool { return false }
@@ -0,0 +1,7 @@
+//go:build unix
+
+package pushover
+
+import "io/fs"
+
+func insecure(mode fs.FileMode) bool { return mode.Perm()&0o077 != 0 }
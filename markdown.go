@@ -0,0 +1,135 @@
+package pushover
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Convert a minimal markdown subset to the HTML supported by pushover messages:
+// **bold** or __bold__, *italic* or _italic_ and [text](url) links. HTML in the
+// input is escaped, a backslash escapes markdown characters. Unmatched markers
+// are kept literally.
+func markdownToHTML(md string) string {
+	var c converter
+	c.convert(md)
+	return c.String()
+}
+
+// Open emphasis marker, remembers its position in the output so it can be
+// replaced by the literal marker if it is never closed.
+type marker struct {
+	delim string
+	pos   int
+}
+
+type converter struct {
+	out   []string
+	stack []marker
+}
+
+func (c *converter) String() string {
+	// unclosed markers are literal text
+	for _, mk := range c.stack {
+		c.out[mk.pos] = mk.delim
+	}
+	c.stack = nil
+	return strings.Join(c.out, "")
+}
+
+func (c *converter) convert(md string) {
+	for i := 0; i < len(md); {
+		switch ch := md[i]; {
+		case ch == '\\' && i+1 < len(md) && strings.IndexByte(`\*_[]()`, md[i+1]) >= 0:
+			c.out = append(c.out, html.EscapeString(md[i+1:i+2]))
+			i += 2
+		case ch == '[':
+			if text, href, n := link(md[i:]); n > 0 {
+				var inner converter
+				inner.convert(text)
+				c.out = append(c.out, `<a href="`+html.EscapeString(href)+`">`, inner.String(), "</a>")
+				i += n
+				break
+			}
+			c.out = append(c.out, "[")
+			i++
+		case ch == '*' || ch == '_':
+			n := 1
+			for i+n < len(md) && md[i+n] == ch {
+				n++
+			}
+			c.emphasis(md, i, n)
+			i += n
+		default:
+			r, size := utf8.DecodeRuneInString(md[i:])
+			c.out = append(c.out, html.EscapeString(string(r)))
+			i += size
+		}
+	}
+}
+
+// Handle a run of n emphasis characters starting at md[i]
+func (c *converter) emphasis(md string, i, n int) {
+	ch := md[i]
+	before, _ := utf8.DecodeLastRuneInString(md[:i])
+	after, _ := utf8.DecodeRuneInString(md[i+n:])
+	canClose := i > 0 && !unicode.IsSpace(before)
+	canOpen := i+n < len(md) && !unicode.IsSpace(after)
+	if ch == '_' {
+		// no emphasis inside words like snake_case
+		canClose = canClose && (i+n == len(md) || !isWordRune(after))
+		canOpen = canOpen && (i == 0 || !isWordRune(before))
+	}
+
+	for canClose && n > 0 && len(c.stack) > 0 {
+		top := c.stack[len(c.stack)-1]
+		if top.delim[0] != ch || len(top.delim) > n {
+			break
+		}
+		c.stack = c.stack[:len(c.stack)-1]
+		tag := tagFor(top.delim)
+		c.out[top.pos] = "<" + tag + ">"
+		c.out = append(c.out, "</"+tag+">")
+		n -= len(top.delim)
+	}
+	for canOpen && n > 0 {
+		l := 1
+		if n >= 2 {
+			l = 2
+		}
+		c.stack = append(c.stack, marker{delim: strings.Repeat(string(ch), l), pos: len(c.out)})
+		c.out = append(c.out, "") // placeholder, set when closed
+		n -= l
+	}
+	if n > 0 {
+		c.out = append(c.out, strings.Repeat(string(ch), n))
+	}
+}
+
+func tagFor(delim string) string {
+	if len(delim) == 2 {
+		return "b"
+	}
+	return "i"
+}
+
+func isWordRune(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+// Parse a [text](url) link at the start of s, returns the number of bytes consumed
+// or 0 if s does not start with a link.
+func link(s string) (text, href string, n int) {
+	end := strings.IndexByte(s, ']')
+	if end < 0 || end+1 >= len(s) || s[end+1] != '(' {
+		return "", "", 0
+	}
+	rparen := strings.IndexByte(s[end+2:], ')')
+	if rparen < 0 {
+		return "", "", 0
+	}
+	href = s[end+2 : end+2+rparen]
+	if href == "" || strings.ContainsAny(href, " \n") {
+		return "", "", 0
+	}
+	return s[1:end], href, end + 2 + rparen + 1
+}
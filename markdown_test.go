@@ -0,0 +1,28 @@
+package pushover
+
+import "testing"
+
+func TestMarkdownToHTML(t *testing.T) {
+	for _, tc := range []struct{ md, want string }{
+		{"plain text", "plain text"},
+		{"**bold** and __bold__", "<b>bold</b> and <b>bold</b>"},
+		{"*italic* and _italic_", "<i>italic</i> and <i>italic</i>"},
+		{"**bold *nested* bold**", "<b>bold <i>nested</i> bold</b>"},
+		{"***both***", "<b><i>both</i></b>"},
+		{"[pushover](https://pushover.net)", `<a href="https://pushover.net">pushover</a>`},
+		{"[**bold** link](https://x.org/?a=1&b=2)", `<a href="https://x.org/?a=1&amp;b=2"><b>bold</b> link</a>`},
+		{`<script> & "quotes"`, "&lt;script&gt; &amp; &#34;quotes&#34;"},
+		{`\*not italic\*`, "*not italic*"},
+		{"snake_case_name", "snake_case_name"},
+		{"2 * 3 * 4", "2 * 3 * 4"},
+		{"**unclosed", "**unclosed"},
+		{"unopened**", "unopened**"},
+		{"[not a link] (x)", "[not a link] (x)"},
+		{"[broken](link", "[broken](link"},
+		{"ünï **cödé**", "ünï <b>cödé</b>"},
+	} {
+		if got := markdownToHTML(tc.md); got != tc.want {
+			t.Errorf("markdownToHTML(%q)=%q, want %q", tc.md, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package pushover
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Errors of a batch of operations like SendMany(), at the index of the operation, nil
+// for operations that succeeded. Not an error itself, use Err() to get an error only if
+// something failed.
+type Errors []error
+
+// The errors of failed operations as MultiError, nil if all operations succeeded
+func (e Errors) Err() error {
+	var errs MultiError
+	for _, err := range e {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Errors of the failed operations of a batch, returned by Err() of batch results.
+// errors.Is() and errors.As() look at all errors, e.g. to check if any send was throttled.
+type MultiError []error
+
+func (e MultiError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e MultiError) Unwrap() []error { return e }
+
+// Errors of checks by name like ValidateAll(), nil for names that passed
+type ErrorMap map[string]error
+
+// The errors as MultiError in name order, each prefixed with its name, nil if all checks
+// passed
+func (e ErrorMap) Err() error {
+	var errs Errors
+	for _, name := range sortedNames(e) {
+		if e[name] != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, e[name]))
+		}
+	}
+	return errs.Err()
+}
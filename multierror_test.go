@@ -0,0 +1,42 @@
+package pushover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMultiError(t *testing.T) {
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	p := load(t)
+	m := p.MustMessage("a1", "r1")
+	m.Throttle(time.Hour)
+	errs := SendMany(context.Background(), []SendJob{{&m, "t", "first"}, {&m, "t", "second"}}, 1)
+	err := errs.Err()
+	if !errors.Is(err, ErrThrottled) || errors.Is(err, ErrDuplicate) {
+		t.Errorf("errors of throttled batch %v do not contain ErrThrottled", errs)
+	}
+	var me MultiError
+	if err.Error() != ErrThrottled.Error() || !errors.As(err, &me) || len(me) != 1 {
+		t.Errorf("error message %q, want only the failure", err)
+	}
+	if err := (Errors{nil, nil}).Err(); err != nil {
+		t.Errorf("batch without failures returned error %v", err)
+	}
+
+	if err := p.Broadcast(context.Background(), "a1", []string{"r2", "work"}, "t", "m").Err(); err != nil {
+		t.Errorf("successful broadcast returned error %v", err)
+	}
+
+	results := ErrorMap{"r1": nil, "r2": ErrInvalidReceiver, "a": &APIError{StatusCode: 400}}
+	err = results.Err()
+	var apiErr *APIError
+	if !errors.Is(err, ErrInvalidReceiver) || !errors.As(err, &apiErr) || err.Error() != "a: "+apiErr.Error()+"; r2: "+ErrInvalidReceiver.Error() {
+		t.Errorf("error map returned %v", err)
+	}
+}
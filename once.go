@@ -0,0 +1,100 @@
+package pushover
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Error returned by SendOnce() for an id sent before
+var ErrAlreadySent = errors.New("pushover message already sent")
+
+// Guards reading and writing of sent files and sending within the process. Not held
+// while a message is sent.
+var sentMu sync.Mutex
+
+// Ids of messages being sent by SendOnce(), keyed by sent file and id
+var sending = map[string]bool{}
+
+// Send a message and wait for the result unless a message with the same id was sent before,
+// even by an earlier run of the program, e.g. to notify on first boot only. Returns
+// ErrAlreadySent for a repeated id, also while the id is being sent. Sent ids are recorded
+// in Pushover.SentFile, or in pushover/sent of the user cache directory if empty, after
+// the message was delivered. Ids must not contain newlines.
+func (m *Message) SendOnce(id, title, message string) error {
+	if id == "" || strings.ContainsAny(id, "\r\n") {
+		return errors.New("invalid pushover send once id")
+	}
+	path, err := m.sentFile()
+	if err != nil {
+		return err
+	}
+	key := path + "\x00" + id
+	sentMu.Lock()
+	ids, err := readSent(path)
+	if err == nil && (ids[id] || sending[key]) {
+		err = ErrAlreadySent
+	}
+	if err != nil {
+		sentMu.Unlock()
+		return err
+	}
+	sending[key] = true
+	sentMu.Unlock()
+	defer func() {
+		sentMu.Lock()
+		delete(sending, key)
+		sentMu.Unlock()
+	}()
+
+	ctx, cancel := timeoutContext(context.Background(), defaultTimeout)
+	defer cancel()
+	if err := m.sendWait(ctx, m.values(title, message), nil, nil); err != nil {
+		return err
+	}
+	sentMu.Lock()
+	defer sentMu.Unlock()
+	return appendSent(path, id)
+}
+
+// Path of the file recording ids of SendOnce()
+func (m *Message) sentFile() (string, error) {
+	if m.p != nil && m.p.SentFile != "" {
+		return m.p.SentFile, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pushover", "sent"), nil
+}
+
+// Read the ids recorded in a sent file, a missing file has none
+func readSent(path string) (map[string]bool, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]bool{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ids := map[string]bool{}
+	for s := bufio.NewScanner(bytes.NewReader(b)); s.Scan(); {
+		ids[s.Text()] = true
+	}
+	return ids, nil
+}
+
+// Add id to a sent file
+func appendSent(path, id string) error {
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return writeAtomic(path, append(b, id+"\n"...))
+}
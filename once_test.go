@@ -0,0 +1,101 @@
+package pushover
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSendOnce(t *testing.T) {
+	var posts atomic.Int32
+	var fail atomic.Bool
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		posts.Add(1)
+		if fail.Load() {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":0,"errors":["message is invalid"]}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	path := filepath.Join(t.TempDir(), "state", "sent")
+	run := func() Message { // a fresh process
+		p := load(t)
+		p.SentFile = path
+		return p.MustMessage("a1", "r1")
+	}
+
+	m := run()
+	if err := m.SendOnce("boot", "host", "booted"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SendOnce("boot", "host", "booted"); err != ErrAlreadySent {
+		t.Errorf("repeated send returned %v, want ErrAlreadySent", err)
+	}
+	m = run()
+	if err := m.SendOnce("boot", "host", "booted"); err != ErrAlreadySent {
+		t.Errorf("send after restart returned %v, want ErrAlreadySent", err)
+	}
+	fail.Store(true)
+	if err := m.SendOnce("upgrade", "host", "upgraded"); err == nil {
+		t.Fatal("rejected send returned no error")
+	}
+	fail.Store(false)
+	m = run()
+	if err := m.SendOnce("upgrade", "host", "upgraded"); err != nil {
+		t.Errorf("send after failed send returned %s", err)
+	}
+	if posts.Load() != 3 {
+		t.Errorf("posted %d messages, want 3", posts.Load())
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != "boot\nupgrade\n" {
+		t.Errorf("sent file contains %q, err=%v", b, err)
+	}
+	if err := m.SendOnce("a\nb", "host", "x"); err == nil {
+		t.Errorf("id with newline accepted")
+	}
+}
+
+func TestSendOnceConcurrent(t *testing.T) {
+	release := make(chan struct{})
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("message") == "slow" {
+			<-release
+		}
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	dir := t.TempDir()
+	p := load(t)
+	p.SentFile = filepath.Join(dir, "slow")
+	slow := p.MustMessage("a1", "r1")
+	q := load(t)
+	q.SentFile = filepath.Join(dir, "fast")
+	fast := q.MustMessage("a1", "r1")
+
+	done := make(chan error)
+	go func() { done <- slow.SendOnce("boot", "host", "slow") }()
+	for {
+		sentMu.Lock()
+		busy := len(sending) > 0
+		sentMu.Unlock()
+		if busy {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := slow.SendOnce("boot", "host", "slow"); err != ErrAlreadySent {
+		t.Errorf("send of an id being sent returned %v, want ErrAlreadySent", err)
+	}
+	if err := fast.SendOnce("boot", "host", "fast"); err != nil {
+		t.Errorf("send to another file while one is in flight returned %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,137 @@
+package openclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fpunkt/pushover"
+)
+
+// Websocket url notifying devices of new messages, replaced by tests
+var pushURL = "wss://client.pushover.net/push"
+
+// Delay before the first reconnect, doubled for every further failed connection
+var (
+	reconnectDelay    = time.Second
+	maxReconnectDelay = 5 * time.Minute
+)
+
+// The push server sends a keepalive every 30 seconds, a silent connection is reconnected
+var keepaliveTimeout = 90 * time.Second
+
+// Errors ending Listen(), the device has to log in or register again
+var (
+	ErrLoginFailed   = errors.New("pushover push login failed")
+	ErrSessionClosed = errors.New("pushover push session closed by another login")
+)
+
+// Listen for new messages in real time and pass them to msgs, oldest first. Messages are
+// downloaded on every notification of the push server and when (re)connecting, messages
+// already passed are skipped, use Delete() to remove them from the server. Connection
+// failures are retried with growing delays up to five minutes, the delay is reset once the
+// server sends a keepalive. Listen blocks until the context is done, the session becomes
+// invalid or the api rejects the download of messages, it does not close msgs.
+func (c *Client) Listen(ctx context.Context, msgs chan<- Message) error {
+	var highest int64
+	delay := reconnectDelay
+	for {
+		healthy, err := c.listen(ctx, msgs, &highest)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, ErrLoginFailed) || errors.Is(err, ErrSessionClosed) || rejected(err) {
+			return err
+		}
+		if healthy {
+			delay = reconnectDelay
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// Listen on a single connection until it fails or the server asks to reconnect. Reports
+// if a keepalive was received, i.e. the session was working.
+func (c *Client) listen(ctx context.Context, msgs chan<- Message, highest *int64) (healthy bool, err error) {
+	ws, err := dialWebsocket(ctx, pushURL)
+	if err != nil {
+		return false, err
+	}
+	defer ws.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			ws.Close() // unblock read
+		case <-done:
+		}
+	}()
+
+	if err := ws.write(opText, []byte("login:"+c.DeviceID+":"+c.Secret+"\n")); err != nil {
+		return false, err
+	}
+	if err := c.sync(ctx, msgs, highest); err != nil {
+		return false, err
+	}
+	for {
+		ws.conn.SetReadDeadline(time.Now().Add(keepaliveTimeout))
+		frame, err := ws.read()
+		if err != nil {
+			return healthy, err
+		}
+		for _, b := range frame {
+			switch b {
+			case '#': // keepalive
+				healthy = true
+			case '!':
+				if err := c.sync(ctx, msgs, highest); err != nil {
+					return healthy, err
+				}
+			case 'R':
+				return healthy, errors.New("reconnect requested")
+			case 'E':
+				return healthy, ErrLoginFailed
+			case 'A':
+				return healthy, ErrSessionClosed
+			default:
+				return healthy, fmt.Errorf("unexpected push frame %q", frame)
+			}
+		}
+	}
+}
+
+// Download messages and pass those newer than highest
+func (c *Client) sync(ctx context.Context, msgs chan<- Message, highest *int64) error {
+	downloaded, err := c.Messages(ctx)
+	if err != nil {
+		return err
+	}
+	for _, m := range downloaded {
+		if m.ID <= *highest {
+			continue
+		}
+		select {
+		case msgs <- m:
+			*highest = m.ID
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Check if the api rejected a request for good, except when asked to slow down
+func rejected(err error) bool {
+	var ae *pushover.APIError
+	return errors.As(err, &ae) && ae.StatusCode >= 400 && ae.StatusCode < 500 && ae.StatusCode != http.StatusTooManyRequests
+}
@@ -0,0 +1,199 @@
+package openclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fpunkt/pushover"
+)
+
+// Push server accepting websocket connections, each connection is handled by the next
+// session function
+func pushServer(t *testing.T, sessions ...func(ws *wsConn)) (*httptest.Server, *messageStore) {
+	store := &messageStore{}
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/messages.json" {
+			if code := store.failing(); code != 0 {
+				w.WriteHeader(code)
+				fmt.Fprint(w, `{"status":0,"request":"req","errors":["failed"]}`)
+				return
+			}
+			fmt.Fprintf(w, `{"status":1,"request":"req","messages":[%s]}`, store.json())
+			return
+		}
+		if r.URL.Path != "/push" || r.Header.Get("Upgrade") != "websocket" {
+			t.Errorf("unexpected request %s", r.URL.Path)
+			return
+		}
+		mu.Lock()
+		if len(sessions) == 0 {
+			mu.Unlock()
+			http.Error(w, "no more sessions", http.StatusServiceUnavailable)
+			return
+		}
+		session := sessions[0]
+		sessions = sessions[1:]
+		mu.Unlock()
+
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+			acceptKey(r.Header.Get("Sec-Websocket-Key")))
+		rw.Flush()
+		ws := &wsConn{conn: conn, r: rw.Reader}
+		login, err := ws.read()
+		if err != nil || string(login) != "login:dev1:s3cr3t\n" {
+			t.Errorf("push login %q, err=%v", login, err)
+			return
+		}
+		session(ws)
+	}))
+	t.Cleanup(srv.Close)
+	apiURL, pushURL = srv.URL, "ws"+strings.TrimPrefix(srv.URL, "http")+"/push"
+	return srv, store
+}
+
+// Messages pending on the server
+type messageStore struct {
+	mu   sync.Mutex
+	ids  []int
+	fail int // status code of failing downloads, zero if they succeed
+}
+
+func (s *messageStore) failing() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fail
+}
+
+func (s *messageStore) add(id int) {
+	s.mu.Lock()
+	s.ids = append(s.ids, id)
+	s.mu.Unlock()
+}
+
+func (s *messageStore) json() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var msgs []string
+	for _, id := range s.ids {
+		msgs = append(msgs, fmt.Sprintf(`{"id":%d,"message":"m%d"}`, id, id))
+	}
+	return strings.Join(msgs, ",")
+}
+
+func TestListen(t *testing.T) {
+	defer func(d time.Duration) { reconnectDelay = d }(reconnectDelay)
+	reconnectDelay = time.Millisecond
+
+	msgs := make(chan Message, 10)
+	var store *messageStore
+	receive := func(want int64) {
+		select {
+		case m := <-msgs:
+			if m.ID != want {
+				t.Errorf("received message %d, want %d", m.ID, want)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("message %d not received", want)
+		}
+	}
+	_, store = pushServer(t,
+		func(ws *wsConn) {
+			receive(1) // downloaded when connecting
+			ws.write(opText, []byte("#"))
+			store.add(2)
+			ws.write(opText, []byte("!"))
+			receive(2)
+			ws.write(opText, []byte("R"))
+		},
+		func(ws *wsConn) {
+			store.add(3)
+			ws.write(opPing, []byte("p"))
+			ws.write(opText, []byte("!"))
+			receive(3) // 1 and 2 are skipped after reconnect
+			ws.write(opText, []byte("E"))
+		},
+	)
+	store.add(1)
+
+	c := Client{Secret: "s3cr3t", DeviceID: "dev1"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Listen(ctx, msgs); !errors.Is(err, ErrLoginFailed) {
+		t.Errorf("listen returned %v, want ErrLoginFailed", err)
+	}
+	if len(msgs) > 0 {
+		t.Errorf("received %d duplicate messages", len(msgs))
+	}
+}
+
+func TestListenCancel(t *testing.T) {
+	pushServer(t, func(ws *wsConn) {
+		ws.read() // wait for the client to close
+	})
+	c := Client{Secret: "s3cr3t", DeviceID: "dev1"}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.Listen(ctx, make(chan Message)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("cancelled listen returned %v", err)
+	}
+}
+
+func TestListenSyncFailing(t *testing.T) {
+	defer func(d time.Duration) { reconnectDelay = d }(reconnectDelay)
+	reconnectDelay = 20 * time.Millisecond
+
+	connects := make(chan time.Time, 4)
+	session := func(ws *wsConn) {
+		connects <- time.Now()
+		ws.write(opText, []byte("#"))
+		ws.read() // wait for the client to close
+	}
+	_, store := pushServer(t, session, session, session, session)
+	store.fail = http.StatusInternalServerError
+
+	c := Client{Secret: "s3cr3t", DeviceID: "dev1"}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Listen(ctx, make(chan Message))
+	var at []time.Time
+	for len(at) < 4 {
+		select {
+		case connected := <-connects:
+			at = append(at, connected)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%d reconnects, want 4", len(at))
+		}
+	}
+	if gap := at[3].Sub(at[2]); gap < 4*reconnectDelay {
+		t.Errorf("reconnected after %s while downloads keep failing, want at least %s", gap, 4*reconnectDelay)
+	}
+}
+
+func TestListenSyncRejected(t *testing.T) {
+	_, store := pushServer(t, func(ws *wsConn) {
+		ws.read() // wait for the client to close
+	})
+	store.fail = http.StatusBadRequest
+
+	c := Client{Secret: "s3cr3t", DeviceID: "dev1"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var apiErr *pushover.APIError
+	if err := c.Listen(ctx, make(chan Message)); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("listen returned %v, want the rejected download", err)
+	}
+}
@@ -0,0 +1,168 @@
+// Package openclient receives pushover messages on a device registered through the Open
+// Client api, for building desktop or embedded clients. Unlike sending, the Open Client
+// api is authenticated with the secret of a user session instead of an application token.
+// See https://pushover.net/api/client
+// (c) fpunkt@icloud.com
+package openclient
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fpunkt/pushover"
+)
+
+// Base url of the pushover api, replaced by tests
+var apiURL = "https://api.pushover.net/1"
+
+// Client for a user session and the device registered for it. Secret and DeviceID should
+// be stored after Login() and Register(), devices must not be registered on every start.
+type Client struct {
+	UserID   string
+	Secret   string
+	DeviceID string
+}
+
+// Log into the pushover account, twofa is the current two-factor code or empty. The
+// returned client has no device yet, see Register().
+func Login(ctx context.Context, email, password, twofa string) (Client, error) {
+	v := url.Values{"email": {email}, "password": {password}}
+	if twofa != "" {
+		v.Set("twofa", twofa)
+	}
+	var r struct {
+		ID     string `json:"id"`
+		Secret string `json:"secret"`
+	}
+	err := call(ctx, http.MethodPost, "/users/login.json", v, &r)
+	return Client{UserID: r.ID, Secret: r.Secret}, err
+}
+
+// Register a new desktop device with given name for the client's user, up to 25
+// characters of letters, numbers, _ and -. The device id is stored in the client.
+func (c *Client) Register(ctx context.Context, name string) error {
+	var r struct {
+		ID string `json:"id"`
+	}
+	v := url.Values{"secret": {c.Secret}, "name": {name}, "os": {"O"}}
+	if err := call(ctx, http.MethodPost, "/devices.json", v, &r); err != nil {
+		return err
+	}
+	c.DeviceID = r.ID
+	return nil
+}
+
+// Message downloaded to the device
+type Message struct {
+	ID       int64 // increasing message id, see Delete()
+	UMID     int64 // unique id across all devices of the user
+	Title    string
+	Message  string
+	App      string // name of the sending application
+	AppID    int64
+	Icon     string // icon name, see https://pushover.net/api/client#icons
+	Date     time.Time
+	Priority pushover.Priority
+	Sound    string
+	URL      string
+	URLTitle string
+	HTML     bool
+	Acked    bool   // emergency message has been acknowledged
+	Receipt  string // receipt of an emergency message, empty otherwise
+}
+
+// Message as sent by the api, flags are 0/1 and times are unix seconds
+type message struct {
+	ID       int64  `json:"id"`
+	UMID     int64  `json:"umid"`
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	App      string `json:"app"`
+	AID      int64  `json:"aid"`
+	Icon     string `json:"icon"`
+	Date     int64  `json:"date"`
+	Priority int    `json:"priority"`
+	Sound    string `json:"sound"`
+	URL      string `json:"url"`
+	URLTitle string `json:"url_title"`
+	HTML     int    `json:"html"`
+	Acked    int    `json:"acked"`
+	Receipt  string `json:"receipt"`
+}
+
+// Download all messages pending for the device, oldest first. Messages are downloaded
+// again until they are deleted with Delete().
+func (c *Client) Messages(ctx context.Context) ([]Message, error) {
+	var r struct {
+		Messages []message `json:"messages"`
+	}
+	v := url.Values{"secret": {c.Secret}, "device_id": {c.DeviceID}}
+	if err := call(ctx, http.MethodGet, "/messages.json", v, &r); err != nil {
+		return nil, err
+	}
+	msgs := make([]Message, len(r.Messages))
+	for i, m := range r.Messages {
+		msgs[i] = Message{
+			ID: m.ID, UMID: m.UMID, Title: m.Title, Message: m.Message,
+			App: m.App, AppID: m.AID, Icon: m.Icon, Date: time.Unix(m.Date, 0),
+			Priority: pushover.Priority(m.Priority), Sound: m.Sound, URL: m.URL, URLTitle: m.URLTitle,
+			HTML: m.HTML == 1, Acked: m.Acked == 1, Receipt: m.Receipt,
+		}
+	}
+	return msgs, nil
+}
+
+// Delete all messages up to and including the message with id highest from the device,
+// call after the downloaded messages have been displayed or stored.
+func (c *Client) Delete(ctx context.Context, highest int64) error {
+	v := url.Values{"secret": {c.Secret}, "message": {strconv.FormatInt(highest, 10)}}
+	return call(ctx, http.MethodPost, "/devices/"+url.PathEscape(c.DeviceID)+"/update_highest_message.json", v, nil)
+}
+
+// Call the api with form values, sent as query for GET requests, and decode the response
+// into out, which may be nil. Errors reported by the api are returned as *pushover.APIError.
+func call(ctx context.Context, method, path string, v url.Values, out any) error {
+	var body io.Reader
+	if method == http.MethodGet {
+		path += "?" + v.Encode()
+	} else {
+		body = strings.NewReader(v.Encode())
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, apiURL+path, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	var r struct {
+		Status  int      `json:"status"`
+		Request string   `json:"request"`
+		Errors  []string `json:"errors"`
+	}
+	if json.Unmarshal(b, &r) != nil || r.Status != 1 {
+		return &pushover.APIError{StatusCode: resp.StatusCode, Request: r.Request, Errors: r.Errors}
+	}
+	if out != nil {
+		return json.Unmarshal(b, out)
+	}
+	return nil
+}
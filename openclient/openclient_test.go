@@ -0,0 +1,78 @@
+package openclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fpunkt/pushover"
+)
+
+func TestClient(t *testing.T) {
+	var deleted string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.URL.Path {
+		case "/users/login.json":
+			if r.PostForm.Get("password") != "pw" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"status":0,"errors":["invalid email and/or password"],"request":"req-1"}`)
+				return
+			}
+			fmt.Fprint(w, `{"status":1,"id":"user1","secret":"s3cr3t","request":"req"}`)
+		case "/devices.json":
+			if r.PostForm.Get("secret") != "s3cr3t" || r.PostForm.Get("name") != "desk" || r.PostForm.Get("os") != "O" {
+				t.Errorf("unexpected registration %v", r.PostForm)
+			}
+			fmt.Fprint(w, `{"status":1,"id":"dev1","request":"req"}`)
+		case "/messages.json":
+			if r.URL.Query().Get("secret") != "s3cr3t" || r.URL.Query().Get("device_id") != "dev1" {
+				t.Errorf("unexpected download %v", r.URL.Query())
+			}
+			fmt.Fprint(w, `{"status":1,"request":"req","messages":[
+				{"id":7,"umid":70,"title":"Backup","message":"done","app":"HomeControl","aid":3,"icon":"hc","date":1700000000,"priority":1,"acked":0,"html":1},
+				{"id":8,"umid":80,"message":"fire","app":"HomeControl","aid":3,"date":1700000060,"priority":2,"acked":1,"receipt":"rcpt"}]}`)
+		case "/devices/dev1/update_highest_message.json":
+			deleted = r.PostForm.Get("message")
+			fmt.Fprint(w, `{"status":1,"request":"req"}`)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+	apiURL = srv.URL
+	ctx := context.Background()
+
+	var apiErr *pushover.APIError
+	if _, err := Login(ctx, "user@example.com", "wrong", ""); !errors.As(err, &apiErr) || apiErr.Request != "req-1" {
+		t.Errorf("failed login returned %v, want APIError", err)
+	}
+	c, err := Login(ctx, "user@example.com", "pw", "")
+	if err != nil || c.UserID != "user1" || c.Secret != "s3cr3t" {
+		t.Fatalf("login returned %+v, err=%v", c, err)
+	}
+	if err := c.Register(ctx, "desk"); err != nil || c.DeviceID != "dev1" {
+		t.Fatalf("register returned device %q, err=%v", c.DeviceID, err)
+	}
+
+	msgs, err := c.Messages(ctx)
+	if err != nil || len(msgs) != 2 {
+		t.Fatalf("downloaded %d messages, err=%v", len(msgs), err)
+	}
+	want := Message{ID: 7, UMID: 70, Title: "Backup", Message: "done", App: "HomeControl", AppID: 3, Icon: "hc",
+		Date: time.Unix(1700000000, 0), Priority: pushover.PriorityHigh, HTML: true}
+	if msgs[0] != want {
+		t.Errorf("downloaded %+v, want %+v", msgs[0], want)
+	}
+	if m := msgs[1]; m.Priority != pushover.PriorityEmergency || !m.Acked || m.Receipt != "rcpt" {
+		t.Errorf("downloaded emergency message %+v", m)
+	}
+
+	if err := c.Delete(ctx, msgs[1].ID); err != nil || deleted != "8" {
+		t.Errorf("delete sent highest message %q, err=%v", deleted, err)
+	}
+}
@@ -0,0 +1,178 @@
+package pushover
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Option to configure a Message, see Set()
+type Option func(*Message)
+
+// Message priority, see https://pushover.net/api#priority
+type Priority int
+
+const (
+	PriorityLowest    Priority = -2 // no notification, only shown in the app
+	PriorityLow       Priority = -1 // quiet notification without sound or vibration
+	PriorityNormal    Priority = 0
+	PriorityHigh      Priority = 1 // bypasses the receiver's quiet hours
+	PriorityEmergency Priority = 2 // repeated until acknowledged, see WithRetry()
+)
+
+// Title markers of Pushover.AnnotatePriority if PriorityMarkers is nil
+var DefaultPriorityMarkers = map[Priority]string{
+	PriorityHigh:      "⚠️ ",
+	PriorityEmergency: "🚨 ",
+}
+
+// Restrict the message to the given devices of the receiver, overriding the devices
+// configured for the receiver. No devices sends to all devices.
+func WithDevice(devices ...string) Option {
+	return func(m *Message) { m.device = devices }
+}
+
+// Send messages with given priority
+func WithPriority(p Priority) Option {
+	return func(m *Message) { m.priority = p }
+}
+
+// Retry and expire used for emergency priority messages without WithRetry(). Tune them
+// at program start, before messages are sent.
+var (
+	DefaultEmergencyRetry  = time.Minute
+	DefaultEmergencyExpire = time.Hour
+)
+
+// Repeat emergency priority messages every retry interval until acknowledged or
+// expire has passed. Zero values use DefaultEmergencyRetry and DefaultEmergencyExpire.
+// The api takes whole seconds, retry at least 30 seconds and expire at most 3 hours,
+// other values make sends return an error.
+func WithRetry(retry, expire time.Duration) Option {
+	return func(m *Message) {
+		if err := checkEmergency(retry, expire); err != nil {
+			m.invalid(err)
+		}
+		m.retry, m.expire = retry, expire
+	}
+}
+
+// Api limits of emergency parameters
+const (
+	minEmergencyRetry  = 30 * time.Second
+	maxEmergencyExpire = 10800 * time.Second
+)
+
+// Check emergency parameters against the api limits, zero values are the defaults
+func checkEmergency(retry, expire time.Duration) error {
+	if retry != 0 {
+		if retry%time.Second != 0 {
+			return fmt.Errorf("pushover emergency retry %s is not whole seconds", retry)
+		}
+		if retry < minEmergencyRetry {
+			return fmt.Errorf("pushover emergency retry %s below minimum of %s", retry, minEmergencyRetry)
+		}
+	}
+	if expire != 0 {
+		if expire%time.Second != 0 {
+			return fmt.Errorf("pushover emergency expire %s is not whole seconds", expire)
+		}
+		if expire < 0 || expire > maxEmergencyExpire {
+			return fmt.Errorf("pushover emergency expire %s out of range 1s to %s", expire, maxEmergencyExpire)
+		}
+	}
+	return nil
+}
+
+// Play given sound instead of the receiver's default sound, see https://pushover.net/api#sounds
+func WithSound(sound string) Option {
+	return func(m *Message) { m.sound = sound }
+}
+
+// Attach a supplementary url to the message, title may be empty to show the url itself.
+// The url must be an absolute http or https url, unless AllowCustomSchemes() is set.
+func WithURL(url, title string) Option {
+	return func(m *Message) { m.url, m.urlTitle = url, title }
+}
+
+// Accept supplementary urls with schemes other than http and https, like pushover:// or
+// other app deep links
+func AllowCustomSchemes() Option {
+	return func(m *Message) { m.customSchemes = true }
+}
+
+// Check the supplementary url of the message
+func (m *Message) checkURL() error {
+	if m.url == "" {
+		return nil
+	}
+	u, err := url.Parse(m.url)
+	if err != nil || u.Scheme == "" {
+		return fmt.Errorf("invalid pushover url %q", m.url)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" && !m.customSchemes {
+		return fmt.Errorf("pushover url scheme %s not allowed without AllowCustomSchemes()", u.Scheme)
+	}
+	return nil
+}
+
+// Delete the message from the receiver's devices after ttl
+func WithTTL(ttl time.Duration) Option {
+	return func(m *Message) { m.ttl = ttl }
+}
+
+// Send messages without title, even if one is given, so pushover shows the app name.
+// The title field is omitted from the form instead of being sent empty.
+func NoTitle() Option {
+	return func(m *Message) { m.noTitle = true }
+}
+
+// Remove NUL and other control characters except newlines from title and message, which
+// garble notifications e.g. of raw log lines. Enabled by default, disable to send text
+// unchanged.
+func SanitizeInput(enabled bool) Option {
+	return func(m *Message) { m.keepControl = !enabled }
+}
+
+// Form fields that cannot be set with WithExtra()
+var coreFields = map[string]bool{"token": true, "user": true, "message": true, "title": true}
+
+// Post an additional form field with every message. This is an escape hatch to use api
+// parameters this package does not support yet, it overrides fields set by other options.
+// Token, user, message and title cannot be set, sending returns an error then.
+func WithExtra(key, value string) Option {
+	return func(m *Message) {
+		if coreFields[key] {
+			m.invalid(fmt.Errorf("pushover field %s cannot be set as extra", key))
+			return
+		}
+		// copy on write, the fields are shared with copies of the message
+		extra := make(url.Values, len(m.extra)+1)
+		for k, vs := range m.extra {
+			extra[k] = vs
+		}
+		extra.Set(key, value)
+		m.extra = extra
+	}
+}
+
+// Record an invalid option, the first one is reported
+func (m *Message) invalid(err error) {
+	if m.err == nil {
+		m.err = err
+	}
+}
+
+// Set emergency parameters, zero values are replaced by the defaults
+func setEmergency(v url.Values, retry, expire time.Duration) {
+	if retry == 0 {
+		retry = DefaultEmergencyRetry
+	}
+	if expire == 0 {
+		expire = DefaultEmergencyExpire
+	}
+	v.Set("priority", strconv.Itoa(int(PriorityEmergency)))
+	v.Set("retry", strconv.Itoa(int(retry/time.Second)))
+	v.Set("expire", strconv.Itoa(int(expire/time.Second)))
+}
@@ -0,0 +1,90 @@
+package pushover
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Keep the throttle across restarts of the program by recording the time of the last send
+// in a file, e.g. for cron jobs exec'ed every minute. Loads the time recorded by a
+// previous run, call it after Throttle(), which resets the time. A missing file is fine.
+// Write errors after sends are reported by LastPersistError(), the throttle then only
+// holds within the process.
+func (m *Message) PersistThrottle(path string) error {
+	var last time.Time
+	b, err := os.ReadFile(path)
+	if err == nil {
+		if last, err = time.Parse(time.RFC3339Nano, strings.TrimSpace(string(b))); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	st := m.state()
+	st.saveMu.Lock()
+	defer st.saveMu.Unlock()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.persist, st.persisted, st.persistErr = path, last, nil
+	if last.After(st.lastsent) {
+		st.lastsent = last
+	}
+	return nil
+}
+
+// Error of the last write of the file set with PersistThrottle(), nil after a successful
+// write. Shared with copies of the message.
+func (m *Message) LastPersistError() error {
+	st := m.state()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.persistErr
+}
+
+// Record the time of the last send if persisted. Called without st.mu, so the synced
+// write does not block sends of copies; a time already recorded is not written again.
+func (st *msgState) save() {
+	st.saveMu.Lock()
+	defer st.saveMu.Unlock()
+	st.mu.Lock()
+	path, last := st.persist, st.lastsent
+	st.mu.Unlock()
+	if path == "" || last.Equal(st.persisted) {
+		return
+	}
+	err := writeAtomic(path, []byte(last.Format(time.RFC3339Nano)+"\n"))
+	if err == nil {
+		st.persisted = last
+	}
+	st.mu.Lock()
+	st.persistErr = err
+	st.mu.Unlock()
+}
+
+// Replace a file atomically, so a crash leaves either the old or the new file
+func writeAtomic(path string, b []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,77 @@
+package pushover
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPersistThrottle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "throttle")
+	clock := &fakeClock{t: time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)}
+	run := func() Message { // a fresh process
+		p := load(t)
+		p.clock = clock
+		m := p.MustMessage("a1", "r1")
+		m.Throttle(time.Hour)
+		if err := m.PersistThrottle(path); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	noop := func() error { return nil }
+
+	m := run()
+	if err := m.runThrottled(noop); err != nil {
+		t.Fatalf("first send returned %s", err)
+	}
+	clock.Advance(30 * time.Minute)
+	m = run()
+	if !m.LastSent().Equal(clock.t.Add(-30*time.Minute)) || m.runThrottled(noop) != ErrThrottled {
+		t.Errorf("send after restart not throttled, last sent %s", m.LastSent())
+	}
+	clock.Advance(30 * time.Minute)
+	m = run()
+	if err := m.runThrottled(noop); err != nil {
+		t.Errorf("send after throttle period returned %s", err)
+	}
+
+	os.WriteFile(path, []byte("garbage"), 0o600)
+	if err := m.PersistThrottle(path); err == nil {
+		t.Errorf("loaded invalid throttle file")
+	}
+	if err := m.PersistThrottle(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("missing throttle file returned %s", err)
+	}
+}
+
+func TestLastPersistError(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	clock := &fakeClock{t: time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)}
+	p := load(t)
+	p.clock = clock
+	m := p.MustMessage("a1", "r1")
+	m.Throttle(time.Minute)
+	if err := m.PersistThrottle(filepath.Join(dir, "throttle")); err != nil {
+		t.Fatal(err)
+	}
+	noop := func() error { return nil }
+
+	os.WriteFile(dir, nil, 0o600) // not a directory
+	if err := m.runThrottled(noop); err != nil {
+		t.Fatalf("send returned %s", err)
+	}
+	if m.LastPersistError() == nil {
+		t.Errorf("failed write of the throttle file not reported")
+	}
+	os.Remove(dir)
+	clock.Advance(time.Minute)
+	m.runThrottled(noop)
+	if err := m.LastPersistError(); err != nil {
+		t.Errorf("successful write of the throttle file reported %s", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "throttle")); err != nil || len(b) == 0 {
+		t.Errorf("throttle file not written, err=%v", err)
+	}
+}
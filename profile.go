@@ -0,0 +1,50 @@
+package pushover
+
+import (
+	"fmt"
+	"time"
+)
+
+// Named message settings from the config, so alert classes are defined declaratively and
+// code only references them by name. Durations are seconds, zero values keep the defaults:
+//
+//	"profiles": {
+//	    "critical": {"priority": 2, "sound": "siren", "retry": 60, "expire": 3600}
+//	}
+type Profile struct {
+	Priority Priority `json:"priority,omitempty"`
+	Sound    string   `json:"sound,omitempty"`
+	Retry    int      `json:"retry,omitempty"`  // emergency retry interval in seconds
+	Expire   int      `json:"expire,omitempty"` // emergency expiry in seconds
+	TTL      int      `json:"ttl,omitempty"`    // seconds until the message is deleted
+}
+
+// Options applying the profile to a message
+func (pr Profile) Options() []Option {
+	var opts []Option
+	if pr.Priority != PriorityNormal {
+		opts = append(opts, WithPriority(pr.Priority))
+	}
+	if pr.Sound != "" {
+		opts = append(opts, WithSound(pr.Sound))
+	}
+	if pr.Retry != 0 || pr.Expire != 0 {
+		opts = append(opts, WithRetry(time.Duration(pr.Retry)*time.Second, time.Duration(pr.Expire)*time.Second))
+	}
+	if pr.TTL != 0 {
+		opts = append(opts, WithTTL(time.Duration(pr.TTL)*time.Second))
+	}
+	return opts
+}
+
+// Create a Message like Message() with the settings of a profile from the config applied.
+//
+//	m, err := p.MessageProfile("HomeControl", "InfoGroup", "critical")
+func (p *Pushover) MessageProfile(app, receiver, profile string) (Message, error) {
+	pr, ok := p.Profiles[profile]
+	m, err := p.Message(app, receiver, pr.Options()...)
+	if err == nil && !ok {
+		err = fmt.Errorf("invalid pushover profile: %s", profile)
+	}
+	return m, err
+}
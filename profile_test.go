@@ -0,0 +1,20 @@
+package pushover
+
+import "testing"
+
+func TestMessageProfile(t *testing.T) {
+	p := load(t)
+	m, err := p.MessageProfile("a1", "r1", "critical")
+	if err != nil {
+		t.Fatalf("cannot create message with profile: %s", err)
+	}
+	if got := m.values("t", "m").Encode(); got != "expire=3600&message=m&priority=2&retry=60&sound=siren&title=t&token=app1&user=rec1" {
+		t.Errorf("profile message posts %s", got)
+	}
+	if _, err := p.MessageProfile("a1", "r1", "missing"); err == nil {
+		t.Errorf("created message with unknown profile")
+	}
+	if _, err := p.MessageProfile("a1", "missing", "critical"); err == nil {
+		t.Errorf("created profile message for unknown receiver")
+	}
+}
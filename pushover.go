@@ -4,52 +4,485 @@ package pushover
 // (c) fpunkt@icloud.com
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
+	"html/template"
 	"io"
+	"io/fs"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
 )
 
 // Holding application and user/group keys to generate Messages. Use Load() or MustLoad() to
 // initiate a Pushover structure, use the Message() function to generate messages.
 type Pushover struct {
-	App map[string]string
-	Rec map[string]string
+	Version int `json:"version,omitempty"` // config schema version, see migrateConfig()
+	App     map[string]string
+	Rec     map[string]Receiver
+
+	// Named message settings, see MessageProfile()
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+
+	// Send messages silently or not at all during quiet hours, nil for none
+	QuietHours *QuietHours `json:"quiet_hours,omitempty"`
+
+	// Send high priority messages as emergency outside business hours, nil for never
+	BusinessHours *BusinessHours `json:"business_hours,omitempty"`
+
+	// Send non-emergency messages with PriorityLowest, without notification, once fewer
+	// messages than this remain in the monthly quota of the app. Zero never downgrades.
+	// The remaining quota is known from previous sends of the app, see WouldExceedQuota().
+	ConserveBelow int `json:"conserve_below,omitempty"`
+
+	// Use the program name as title of messages sent without title, handy for scripts
+	DefaultTitleFromProcess bool `json:"default_title_from_process,omitempty"`
+
+	// Prepended to the title of every message, e.g. "[web01] " to tell which host sent an
+	// alert. Messages without title get the prefix in front of the message instead.
+	SourcePrefix string `json:"source_prefix,omitempty"`
+
+	// Prefix titles of high and emergency priority messages with a marker like "🚨 " to
+	// triage notifications at a glance. Markers are taken from PriorityMarkers, or from
+	// DefaultPriorityMarkers if nil. Messages without title get the marker in front of
+	// the message instead.
+	AnnotatePriority bool                `json:"annotate_priority,omitempty"`
+	PriorityMarkers  map[Priority]string `json:"priority_markers,omitempty"`
+
+	// Called when pushover permanently rejects a message, e.g. because the monthly quota is
+	// exceeded or a key is invalid, to route it to an alternative channel like email.
+	// Not called for throttled messages or transient network and server errors.
+	Fallback func(title, message string) error `json:"-"`
+
+	// Number of times a failed api call is repeated, zero disables retries. Every retry
+	// waits twice as long as the previous one, starting at one second, or longer if the
+	// api asks so with a Retry-After header.
+	Retries int `json:"retries,omitempty"`
+
+	// Total time an api call may spend retrying, zero for no limit. Guards against runaway
+	// retries of a misconfigured Retries or RetryIf during long outages, a call that would
+	// wait beyond the budget returns ErrRetryBudget.
+	RetryBudget time.Duration `json:"-"`
+
+	// Decide if a failed api call is retried, DefaultRetryIf() if nil. Resp is nil if the
+	// api did not respond, otherwise its body has been read already. Throttled messages
+	// and requests rejected with a 4xx status other than 429 are never retried.
+	RetryIf func(resp *http.Response, err error) bool `json:"-"`
+
+	// Time SendIdempotent() remembers a key, ten minutes if zero. In the config file
+	// "idempotency_ttl" is in seconds or a duration string like "1h30m".
+	IdempotencyTTL time.Duration `json:"-"`
+
+	// File recording the ids of SendOnce(), pushover/sent of the user cache dir if empty
+	SentFile string `json:"sent_file,omitempty"`
+
+	// Skip receivers missing in the config in broadcasts instead of failing, so shared
+	// alert definitions can name receivers only some deployments configure
+	SkipUnknownReceivers bool `json:"skip_unknown_receivers,omitempty"`
+
+	// Number of background sends in flight at once, zero for no limit. Protects memory and
+	// the api during event storms. Further sends block until a send finishes, or return
+	// ErrTooManySends with DropWhenBusy. Blocking makes Send() and the other background
+	// sends wait in the caller, handlers of NewSlogHandler() never block but drop records.
+	// The limit is fixed by the first background send.
+	MaxConcurrentSends int  `json:"max_concurrent_sends,omitempty"`
+	DropWhenBusy       bool `json:"drop_when_busy,omitempty"`
+
+	defaultRec string       // receiver of MessageApp()
+	clock      clock        // time source of throttles, real time if nil
+	httpClient *http.Client // client of api calls, see WithHTTPClient()
+
+	st *state // shared by all messages, created on first use
+}
+
+// Accept common aliases of existing configs, "applications" for the app section and
+// "receivers" or "users" for the rec section. Section names are case insensitive, so "App"
+// and "Rec" work, too. Entries of the app and rec sections win over aliased ones.
+func (p *Pushover) UnmarshalJSON(b []byte) error {
+	type plain Pushover
+	aux := struct {
+		*plain
+		Applications   map[string]string   `json:"applications"`
+		Receivers      map[string]Receiver `json:"receivers"`
+		Users          map[string]Receiver `json:"users"`
+		IdempotencyTTL json.RawMessage     `json:"idempotency_ttl"`
+	}{plain: (*plain)(p)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	p.App = merge(p.App, aux.Applications)
+	p.Rec = merge(merge(p.Rec, aux.Receivers), aux.Users)
+	if aux.IdempotencyTTL != nil {
+		d, err := parseDuration(aux.IdempotencyTTL)
+		if err != nil {
+			return fmt.Errorf("idempotency_ttl: %w", err)
+		}
+		p.IdempotencyTTL = d
+	}
+	return nil
+}
+
+// Decode a duration of the config, given in seconds or as string like "1h30m"
+func parseDuration(b json.RawMessage) (time.Duration, error) {
+	var secs float64
+	if err := json.Unmarshal(b, &secs); err == nil {
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return 0, fmt.Errorf("duration must be seconds or a string: %s", b)
+	}
+	return time.ParseDuration(s)
+}
+
+// Add entries of alias missing in m
+func merge[V any](m, alias map[string]V) map[string]V {
+	if len(alias) == 0 {
+		return m
+	}
+	if m == nil {
+		m = make(map[string]V, len(alias))
+	}
+	for k, v := range alias {
+		if _, ok := m[k]; !ok {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// Receiver key with optional default device restriction. In the config file a receiver is
+// either just the key or an object with key and devices:
+//
+//	"rec": {
+//	    "r1": "rec1",
+//	    "work": {"key": "rec2", "devices": ["work-phone"]}
+//	}
+type Receiver struct {
+	Key     string   `json:"key"`
+	Devices []string `json:"devices,omitempty"`
+}
+
+func (r *Receiver) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &r.Key); err == nil {
+		return nil
+	}
+	type plain Receiver
+	return json.Unmarshal(b, (*plain)(r))
+}
+
+// Runtime state of a Pushover shared by all its messages. Kept behind a pointer
+// so Pushover values can be copied and returned from Load().
+type state struct {
+	mu         sync.Mutex
+	pairs      map[string]*pairThrottle // keyed by pairKey(app, rec)
+	limit      map[string]limit         // last observed limit headers, keyed by app token
+	latency    map[string]*latency      // round trip times, keyed by receiver name
+	groups     map[string]*msgState     // send state of group messages, see GroupMessage()
+	idempotent map[string]*idempotent   // keys of SendIdempotent()
+	sends      chan struct{}            // slots of background sends, see MaxConcurrentSends
+
+	client *http.Client
+}
+
+// Application message limits as reported by the X-Limit-App-* response headers
+type limit struct {
+	limit, remaining int
+	reset            time.Time
+}
+
+// Throttle enforced collectively for all messages of an app/receiver pair
+type pairThrottle struct {
+	throttle time.Duration
+	lastsent time.Time
 }
 
+// Guards lazy creation of state
+var stateMu sync.Mutex
+
+func (p *Pushover) state() *state {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	if p.st == nil {
+		p.st = &state{
+			pairs:      map[string]*pairThrottle{},
+			limit:      map[string]limit{},
+			latency:    map[string]*latency{},
+			groups:     map[string]*msgState{},
+			idempotent: map[string]*idempotent{},
+		}
+	}
+	return p.st
+}
+
+func pairKey(app, rec string) string { return app + "\x00" + rec }
+
 // Pushover Message for specific Application and Receiver keys.
 // Message title and text are passed to the Send() method. A message can be reused
 // to send arbritary number of messages. Messages can be throttled using Throttle().
 type Message struct {
-	app, rec string
+	p                *Pushover
+	app, rec         string
+	appName, recName string
+	device           []string
+	group            string   // group key of GroupMessage(), default title
+	batch            []string // receiver names of a BroadcastBatched() batch, recName is empty
+
+	priority      Priority
+	sound         string
+	url, urlTitle string
+	ttl           time.Duration
+	retry, expire time.Duration // repeat emergency messages every retry until expire
+	extra         url.Values    // additional form fields, see WithExtra()
+	truncate      TruncateMode  // handling of messages exceeding the api limit
+	noTitle       bool          // omit the title, see NoTitle()
+	keepControl   bool          // send control characters, see SanitizeInput()
+	customSchemes bool          // allow urls other than http, see AllowCustomSchemes()
+	maxWidth      int           // of attached images in pixels, see WithMaxImageSize()
+	maxHeight     int
+
+	err error // first invalid option, returned by sends
+
+	form url.Values // fields of all sends, cached by cache()
 
 	// Limit number of messages send to 1 message every throttle period
 	throttle time.Duration
+
+	// Double the throttle period on every failed send, from initial up to max
+	backoffInitial, backoffMax time.Duration
+
+	// Suppress messages with same content as one sent within the window
+	dedup time.Duration
+
+	// Advance the throttle only on successful sends, see ThrottleOnSuccess()
+	onSuccess bool
+
+	st *msgState
+}
+
+// Send state of a message, updated by background sends. Kept behind a pointer
+// so messages can be passed by value.
+type msgState struct {
+	mu       sync.Mutex
 	lastsent time.Time
+	backoff  time.Duration // current backoff throttle period, zero after a success
+
+	allowed, dropped int       // sends passing and dropped by the throttle, see ThrottleStats()
+	persist          string    // file recording lastsent, see PersistThrottle()
+	persistErr       error     // of the last write of the persist file, see LastPersistError()
+	inflight         int       // sends with ThrottleOnSuccess() waiting for their result
+	lastfailed       time.Time // last failed send with ThrottleOnSuccess(), starts the backoff
+
+	group    bool          // shared by the messages of a group, see GroupMessage()
+	throttle time.Duration // throttle period of the group
+
+	recent map[uint64]time.Time // content hashes sent within the dedup window
+
+	saveMu    sync.Mutex // serializes writes of the persist file, taken before mu
+	persisted time.Time  // lastsent recorded in the persist file, guarded by saveMu
+
+	last    uint64 // content hash of the last message sent with SendIfChanged()
+	hasLast bool
+
+	pending atomic.Int64  // background sends in flight
+	idle    chan struct{} // closed when the last pending send finishes
+}
+
+func (m *Message) state() *msgState {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	if m.st == nil {
+		m.st = &msgState{}
+	}
+	return m.st
 }
 
 // Open app/usr database (typically like /usr/local/etc/pushover.json) or panic.
-func MustLoad(fname string) Pushover {
-	p, err := Load(fname)
+func MustLoad(fname string, opts ...LoadOption) Pushover {
+	p, err := Load(fname, opts...)
 	if err != nil {
 		panic("Pushover Open: " + err.Error())
 	}
 	return p
 }
 
-// Load your application and receiver keys from a json-file
-func Load(fname string) (Pushover, error) {
-	b, err := os.ReadFile(fname)
+// Errors returned by Load functions, test with errors.Is to distinguish a missing config,
+// e.g. to fall back to environment variables, from a broken one.
+var (
+	ErrConfigNotFound = errors.New("pushover config not found")
+	ErrConfigInvalid  = errors.New("pushover config invalid")
+)
+
+// Standard input read by Load("-"), replaced by tests
+var stdin io.Reader = os.Stdin
+
+// Load your application and receiver keys from a json-file. The name "-" reads the config
+// from standard input like LoadReader(), e.g. for `fetch-secrets | mytool`.
+func Load(fname string, opts ...LoadOption) (Pushover, error) {
+	if fname == "-" {
+		return LoadReader(stdin, opts...)
+	}
+	o := newLoadOptions(opts)
+	f, err := os.Open(fname)
+	if err != nil {
+		return Pushover{}, readError(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return Pushover{}, err
+	}
+	if err := o.checkFile(info); err != nil {
+		return Pushover{}, fmt.Errorf("%w: %s has mode %s", err, fname, info.Mode().Perm())
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return Pushover{}, err
+	}
+	return unmarshal(b, o)
+}
+
+func readError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %w", ErrConfigNotFound, err)
+	}
+	return err
+}
+
+// Load application and receiver keys from a reader, e.g. a config passed on stdin
+// or a file opened from an embed.FS.
+func LoadReader(r io.Reader, opts ...LoadOption) (Pushover, error) {
+	b, err := io.ReadAll(r)
 	if err != nil {
 		return Pushover{}, err
 	}
+	return unmarshal(b, newLoadOptions(opts))
+}
+
+// Load application and receiver keys from a file system, typically an embed.FS
+// to ship a baked-in config with a single binary:
+//
+//	//go:embed pushover.json
+//	var config embed.FS
+//
+//	p, err := pushover.LoadFS(config, "pushover.json")
+func LoadFS(fsys fs.FS, name string, opts ...LoadOption) (Pushover, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return Pushover{}, readError(err)
+	}
+	return unmarshal(b, newLoadOptions(opts))
+}
+
+func unmarshal(b []byte, o loadOptions) (Pushover, error) {
 	p := Pushover{}
-	err = json.Unmarshal(b, &p)
-	return p, err
+	if err := json.Unmarshal(b, &p); err != nil {
+		return p, fmt.Errorf("%w: %w", ErrConfigInvalid, err)
+	}
+	if err := migrateConfig(&p); err != nil {
+		return p, fmt.Errorf("%w: %w", ErrConfigInvalid, err)
+	}
+	if err := expandEnv(&p); err != nil {
+		return p, fmt.Errorf("%w: %w", ErrConfigInvalid, err)
+	}
+	if err := p.QuietHours.check(); err != nil {
+		return p, fmt.Errorf("%w: %w", ErrConfigInvalid, err)
+	}
+	if err := p.BusinessHours.check(); err != nil {
+		return p, fmt.Errorf("%w: %w", ErrConfigInvalid, err)
+	}
+	if err := o.checkConfig(&p); err != nil {
+		return p, fmt.Errorf("%w: %w", ErrConfigInvalid, err)
+	}
+	o.configure(&p)
+	return p, nil
+}
+
+// Expand environment variables referenced in application tokens and receiver keys like
+// "${PUSHOVER_TOKEN}", so configs can be committed without secrets. Unset variables are
+// an error, values without variables are kept literally.
+func expandEnv(p *Pushover) error {
+	var err error
+	expand := func(section, name, value string) string {
+		if !strings.Contains(value, "$") {
+			return value
+		}
+		return os.Expand(value, func(v string) string {
+			s, ok := os.LookupEnv(v)
+			if !ok && err == nil {
+				err = fmt.Errorf("%s %s: environment variable %s not set", section, name, v)
+			}
+			return s
+		})
+	}
+	for name, token := range p.App {
+		p.App[name] = expand("app", name, token)
+	}
+	for name, r := range p.Rec {
+		r.Key = expand("rec", name, r.Key)
+		p.Rec[name] = r
+	}
+	return err
+}
+
+// Current config schema version
+const configVersion = 1
+
+// Upgrade a config read with an older schema to the current one.
+//
+//	0: no version field, only app and rec sections with plain keys
+//	1: receivers may be objects with key and devices
+func migrateConfig(p *Pushover) error {
+	if p.Version > configVersion {
+		return fmt.Errorf("config version %d is newer than supported version %d", p.Version, configVersion)
+	}
+	if p.Version < 0 {
+		return fmt.Errorf("invalid config version %d", p.Version)
+	}
+	if p.Version == 0 {
+		// plain receiver keys are read as receivers without devices already
+		p.Version = 1
+	}
+	if p.App == nil {
+		p.App = map[string]string{}
+	}
+	if p.Rec == nil {
+		p.Rec = map[string]Receiver{}
+	}
+	return nil
+}
+
+// Timeout for calls that wait for the api without an explicit timeout
+const defaultTimeout = 30 * time.Second
+
+// Load config, create a message and send it in one call, waiting for the result. Handy
+// for scripts and cron one-liners:
+//
+//	pushover.Notify("/usr/local/etc/pushover.json", "HomeControl", "InfoGroup", "Backup", "done")
+//
+// The config is reloaded on every call, long-running programs should Load() once and
+// reuse their messages.
+func Notify(configFile, app, receiver, title, message string) error {
+	p, err := Load(configFile)
+	if err != nil {
+		return err
+	}
+	m, err := p.Message(app, receiver)
+	if err != nil {
+		return err
+	}
+	return m.SendAndWait(title, message, defaultTimeout)
 }
 
 // Check if all apps are valid. Can be used for early error/typo discovery
@@ -85,15 +518,18 @@ func (p *Pushover) MustRec(keys ...string) *Pushover {
 
 // Create a Message for given Application and Receiver keys.
 // The Message can be sent later with given title and text, a message can be sent multiple times.
-// Message validates the pushover Application and Receiver key
+// Message validates the pushover Application and Receiver key and restricts the message
+// to the devices configured for the receiver, use WithDevice() to override. Options are
+// applied like with Set().
 //
 //	p := pushover.MustOpen("/usr/local/etc/pushover.json")
-//	m, _ := Message("HomeControl", "InfoGroup")
+//	m, _ := Message("HomeControl", "InfoGroup", pushover.WithSound("siren"))
 //	m.Send("Hello", "there")
-func (p *Pushover) Message(app, receiver string) (Message, error) {
+func (p *Pushover) Message(app, receiver string, opts ...Option) (Message, error) {
 	a, aok := p.App[app]
 	r, rok := p.Rec[receiver]
-	m := Message{app: a, rec: r}
+	m := Message{p: p, app: a, rec: r.Key, appName: app, recName: receiver, device: r.Devices, st: &msgState{}}
+	m.Set(opts...)
 	if !aok {
 		return m, fmt.Errorf("invalid pushover application: %s", app)
 	}
@@ -103,73 +539,736 @@ func (p *Pushover) Message(app, receiver string) (Message, error) {
 	return m, nil
 }
 
+// Devices configured for a receiver in the config, nil for receivers without device
+// restriction or unknown receivers. See ActiveDevices() for the devices known to the api.
+func (p *Pushover) Devices(receiver string) []string {
+	devices := p.Rec[receiver].Devices
+	if devices == nil {
+		return nil
+	}
+	return append([]string(nil), devices...)
+}
+
+// Set the receiver used by MessageApp(), handy for single-user setups
+func (p *Pushover) SetDefaultReceiver(name string) { p.defaultRec = name }
+
+// Create a Message like Message() for the default receiver set with SetDefaultReceiver()
+func (p *Pushover) MessageApp(app string, opts ...Option) (Message, error) {
+	if p.defaultRec == "" {
+		return Message{}, errors.New("no default pushover receiver set")
+	}
+	return p.Message(app, p.defaultRec, opts...)
+}
+
+// First application and receiver name in alphabetical order, for trivial tools that just
+// send to whatever is configured. Sorting makes the choice the same on every run despite
+// random map order. Ok is false if no application or no receiver is configured.
+func (p *Pushover) First() (app, rec string, ok bool) {
+	if len(p.App) == 0 || len(p.Rec) == 0 {
+		return "", "", false
+	}
+	return sortedNames(p.App)[0], sortedNames(p.Rec)[0], true
+}
+
+// Names of a config section in alphabetical order
+func sortedNames[V any](section map[string]V) []string {
+	names := make([]string, 0, len(section))
+	for name := range section {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Create a Message, panics if application or receiver key cannot be found.
 //
 //	p := pushover.MustOpen("/usr/local/etc/pushover.json")
-//	m := MustMessage("HomeControl", "InfoGroup")
+//	m := MustMessage("HomeControl", "InfoGroup", pushover.WithPriority(pushover.PriorityHigh))
 //	m.Send("Hello", "there")
-func (p *Pushover) MustMessage(app, receiver string) Message {
-	m, err := p.Message(app, receiver)
+func (p *Pushover) MustMessage(app, receiver string, opts ...Option) Message {
+	m, err := p.Message(app, receiver, opts...)
 	if err != nil {
 		panic(fmt.Sprintf("pushover cannot create message for app=%s, rec=%s", app, receiver))
 	}
 	return m
 }
 
+// Copy of the message sent to a different receiver key, e.g. chosen by severity at runtime.
+// All settings are kept except the device restriction, which belongs to the original
+// receiver. The copy has its own throttle state. If the key belongs to exactly one
+// configured receiver, pair throttles and latency apply to that receiver, otherwise they
+// do not apply to the copy.
+func (m Message) To(receiverToken string) (Message, error) {
+	if receiverToken == "" {
+		return m, errors.New("empty pushover receiver")
+	}
+	m.rec, m.recName, m.device = receiverToken, m.p.receiverName(receiverToken), nil
+	m.cache()
+	return m.Fresh(), nil
+}
+
+// Name of the only configured receiver with key, empty if there is none or several
+func (p *Pushover) receiverName(key string) string {
+	if p == nil {
+		return ""
+	}
+	name := ""
+	for n, r := range p.Rec {
+		if r.Key == key {
+			if name != "" {
+				return ""
+			}
+			name = n
+		}
+	}
+	return name
+}
+
+// Copy of the message with all options but its own, reset throttle state. This is the safe
+// way to hand out messages to a pool of workers, each worker sends and throttles
+// independently of the others. Pair throttles set with ThrottlePair() remain shared.
+func (m Message) Fresh() Message {
+	m.st = &msgState{}
+	return m
+}
+
+// Clear all options and throttle settings of the message, only application and receiver
+// are kept. The receiver's configured devices are cleared, too. The throttle timer is
+// reset, so the next message will be sent unconditionally. Pair throttles set with
+// ThrottlePair() are not affected.
+func (m *Message) Reset() {
+	*m = Message{p: m.p, app: m.app, rec: m.rec, appName: m.appName, recName: m.recName, group: m.group, st: m.st}
+	m.cache()
+	m.ResetThrottle()
+	st := m.state()
+	st.mu.Lock()
+	st.backoff, st.throttle = 0, 0
+	st.mu.Unlock()
+}
+
+// Apply options to the message
+func (m *Message) Set(opts ...Option) {
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.cache()
+}
+
 // Error that is returned when messages are being send to fast and discarded.
 var ErrThrottled = errors.New("pushover sending too fast - throttled")
 
 // Reset throttle timer, next message will be sent unconditionally.
-func (m *Message) ResetThrottle() { m.lastsent = time.Time{} }
+func (m *Message) ResetThrottle() {
+	st := m.state()
+	st.mu.Lock()
+	st.lastsent, st.lastfailed = time.Time{}, time.Time{}
+	st.mu.Unlock()
+	st.save()
+}
+
+// Time of the last send attempt that passed the throttle, regardless of whether it was
+// delivered successfully. With ThrottleOnSuccess() only successful sends count. Zero if
+// nothing has been sent or after ResetThrottle().
+func (m *Message) LastSent() time.Time {
+	st := m.state()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.lastsent
+}
 
 // Limit messages to one message per specified intervall
 func (m *Message) Throttle(d time.Duration) {
 	m.throttle = d
+	st := m.state()
+	st.mu.Lock()
+	st.throttle = d
+	st.mu.Unlock()
 	m.ResetThrottle()
 }
 
+// Advance the throttle only when a send succeeds, so a failed send can be retried right
+// away. By default every send attempt passing the throttle starts a new throttle period.
+// While a send is in flight further sends are throttled, background sends count once
+// their result is known. The period starts at the time the successful send was made.
+func (m *Message) ThrottleOnSuccess(enabled bool) { m.onSuccess = enabled }
+
+// Throttle period set with Throttle(), zero if the message is not throttled
+func (m *Message) ThrottleInterval() time.Duration {
+	st := m.state()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return m.interval(st)
+}
+
+// Throttle period of the message, the group's period for group messages. st.mu must be held.
+func (m *Message) interval(st *msgState) time.Duration {
+	if st.group {
+		return st.throttle
+	}
+	return m.throttle
+}
+
+// Limit messages for an app/receiver pair to one message per specified intervall. Unlike
+// Throttle() this is enforced collectively for all messages created for the pair, so code
+// creating fresh messages in a loop cannot burst. A zero duration removes the limit.
+func (p *Pushover) ThrottlePair(app, rec string, d time.Duration) {
+	st := p.state()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if d <= 0 {
+		delete(st.pairs, pairKey(app, rec))
+		return
+	}
+	st.pairs[pairKey(app, rec)] = &pairThrottle{throttle: d}
+}
+
+// Throttle messages while sends keep failing, e.g. during a network outage. After each
+// failed send the throttle period doubles, starting at initial up to max. The first
+// successful send removes the backoff again. Throttle() remains in effect, the longer
+// of both periods applies.
+func (m *Message) BackoffThrottle(initial, max time.Duration) {
+	m.backoffInitial, m.backoffMax = initial, max
+	st := m.state()
+	st.mu.Lock()
+	st.backoff = 0
+	st.mu.Unlock()
+}
+
+// Adjust backoff throttle period to the result of a send
+func (m *Message) recordResult(err error) {
+	if m.backoffMax <= 0 {
+		return
+	}
+	st := m.state()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	switch {
+	case err == nil:
+		st.backoff = 0
+	case st.backoff == 0:
+		st.backoff = m.backoffInitial
+	default:
+		st.backoff *= 2
+	}
+	if st.backoff > m.backoffMax {
+		st.backoff = m.backoffMax
+	}
+}
+
 func (m *Message) runThrottled(fn func() error) error {
-	now := time.Now()
-	if m.throttle > 0 && now.Sub(m.lastsent) < m.throttle {
+	return m.runThrottledAsync(func(done func(error)) error {
+		err := fn()
+		done(err)
+		return err
+	})
+}
+
+// Run fn unless throttled, fn calls done exactly once with the result of the send, which
+// may be after fn returned for background sends. With ThrottleOnSuccess() the throttle
+// advances when done reports success.
+func (m *Message) runThrottledAsync(fn func(done func(error)) error) error {
+	now := m.now()
+	st := m.state()
+	st.mu.Lock()
+	if m.throttled(st, now) || m.p != nil && !m.p.passPair(m.appName, m.recName, now) {
+		st.dropped++
+		st.mu.Unlock()
 		return ErrThrottled
 	}
-	m.lastsent = now
-	return fn()
+	st.allowed++
+	if !m.onSuccess {
+		st.lastsent = now
+		st.mu.Unlock()
+		st.save()
+		return fn(func(error) {})
+	}
+	st.inflight++
+	st.mu.Unlock()
+	return fn(func(err error) {
+		st.mu.Lock()
+		st.inflight--
+		sent := false
+		switch {
+		case err != nil && now.After(st.lastfailed):
+			st.lastfailed = now
+		case err == nil && now.After(st.lastsent):
+			st.lastsent, sent = now, true
+		}
+		st.mu.Unlock()
+		if sent {
+			st.save()
+		}
+	})
 }
 
-func (m *Message) pushover(title, message string, timeout time.Duration) error {
-	client := &http.Client{Timeout: timeout}
-	resp, err := client.PostForm("https://api.pushover.net/1/messages.json", url.Values{
-		"token":   {m.app},
-		"user":    {m.rec},
-		"message": {message},
-		"title":   {title},
-	})
-	if err != nil {
-		return err
+// Check if a send would be throttled now, without sending or advancing the throttle timer.
+// Schedulers can use it to decide whether to send or defer a message.
+func (m *Message) WouldThrottle() bool {
+	now := m.now()
+	st := m.state()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if m.throttled(st, now) {
+		return true
+	}
+	if m.p == nil {
+		return false
+	}
+	pst := m.p.state()
+	pst.mu.Lock()
+	defer pst.mu.Unlock()
+	pt := pst.pairs[pairKey(m.appName, m.recName)]
+	return pt != nil && pt.throttled(now)
+}
+
+// Number of sends allowed and dropped by throttling over the lifetime of the message,
+// shared with its copies. Shows how much traffic the throttle sheds.
+func (m *Message) ThrottleStats() (allowed, dropped int) {
+	st := m.state()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.allowed, st.dropped
+}
+
+// Check the message's own throttle, st.mu must be held
+func (m *Message) throttled(st *msgState, now time.Time) bool {
+	throttle := m.interval(st)
+	if st.backoff > throttle {
+		throttle = st.backoff
+	}
+	// a failed send with ThrottleOnSuccess() does not count as sent, but starts the backoff
+	return throttle > 0 && (now.Sub(st.lastsent) < throttle || now.Sub(st.lastfailed) < st.backoff || st.inflight > 0)
+}
+
+func (pt *pairThrottle) throttled(now time.Time) bool { return now.Sub(pt.lastsent) < pt.throttle }
+
+// Check pair throttle and consume it if the message may be sent
+func (p *Pushover) passPair(app, rec string, now time.Time) bool {
+	st := p.state()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	pt := st.pairs[pairKey(app, rec)]
+	if pt == nil {
+		return true
+	}
+	if pt.throttled(now) {
+		return false
+	}
+	pt.lastsent = now
+	return true
+}
+
+// Estimate if sending n more messages would exceed the monthly quota of the message's
+// application. The estimate relies on the X-Limit-App-Remaining header observed on the
+// last send of any message for the same application, it returns false if no limit has
+// been observed yet.
+func (m *Message) WouldExceedQuota(n int) bool {
+	remaining, ok := m.remaining()
+	return ok && n > remaining
+}
+
+// Remaining messages of the message's application as last observed, false if unknown
+// or the limit has been reset since
+func (m *Message) remaining() (int, bool) {
+	if m.p == nil {
+		return 0, false
+	}
+	st := m.p.state()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	l, ok := st.limit[m.app]
+	if !ok || (!l.reset.IsZero() && m.now().After(l.reset)) {
+		return 0, false
+	}
+	return l.remaining, true
+}
+
+// Downgrade message values to PriorityLowest if the remaining quota is below ConserveBelow,
+// emergency messages are kept
+func (m *Message) conserve(v url.Values) {
+	if m.p == nil || m.p.ConserveBelow <= 0 || v.Get("priority") == strconv.Itoa(int(PriorityEmergency)) {
+		return
+	}
+	if remaining, ok := m.remaining(); ok && remaining < m.p.ConserveBelow {
+		v.Set("priority", strconv.Itoa(int(PriorityLowest)))
+	}
+}
+
+// Remember the limit headers of a response for the message's application
+func (m *Message) observeLimit(h http.Header) {
+	remaining, err := strconv.Atoi(h.Get("X-Limit-App-Remaining"))
+	if err != nil || m.p == nil {
+		return
+	}
+	l := limit{remaining: remaining}
+	l.limit, _ = strconv.Atoi(h.Get("X-Limit-App-Limit"))
+	if reset, err := strconv.ParseInt(h.Get("X-Limit-App-Reset"), 10, 64); err == nil {
+		l.reset = time.Unix(reset, 0)
+	}
+	st := m.p.state()
+	st.mu.Lock()
+	st.limit[m.app] = l
+	st.mu.Unlock()
+}
+
+// Form fields to send title and message. Fields that do not change between sends are
+// built once by cache() and only copied here.
+func (m *Message) values(title, message string) url.Values {
+	form := m.form
+	if form == nil {
+		form = m.fields()
+	}
+	v := make(url.Values, len(form)+2)
+	for k, vs := range form {
+		v[k] = vs
+	}
+	if !m.keepControl {
+		title, message = sanitize(title), sanitize(message)
+	}
+	if m.noTitle {
+		title = ""
+	} else if title == "" {
+		title = m.group
+	}
+	if title == "" && !m.noTitle && m.p != nil && m.p.DefaultTitleFromProcess {
+		title = filepath.Base(os.Args[0])
+	}
+	if m.p != nil && m.p.SourcePrefix != "" {
+		if title != "" {
+			title = m.p.SourcePrefix + title
+		} else {
+			message = m.p.SourcePrefix + message
+		}
+	}
+	v.Set("message", message)
+	if !m.noTitle {
+		v.Set("title", title)
+	}
+	return v
+}
+
+// Prefix the title, or the message if there is none, with the marker of the priority of
+// values v. Applied once the priority is final, after quiet hours and the like.
+func (p *Pushover) annotate(v url.Values) {
+	marker := p.marker(v)
+	if marker == "" {
+		return
 	}
+	if title := v.Get("title"); title != "" {
+		v.Set("title", marker+title)
+	} else {
+		v.Set("message", marker+v.Get("message"))
+	}
+}
+
+// Marker of message values with AnnotatePriority, empty if none
+func (p *Pushover) marker(v url.Values) string {
+	if p == nil || !p.AnnotatePriority {
+		return ""
+	}
+	priority, _ := strconv.Atoi(v.Get("priority"))
+	if Priority(priority) < PriorityHigh {
+		return ""
+	}
+	markers := p.PriorityMarkers
+	if markers == nil {
+		markers = DefaultPriorityMarkers
+	}
+	return markers[Priority(priority)]
+}
+
+// Remove control characters except newlines
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' {
+			return -1
+		}
+		return r
+	}, s)
+}
 
-	defer resp.Body.Close()
+// Cache the fields of all sends, call after changing the message's options
+func (m *Message) cache() { m.form = m.fields() }
 
-	// Only 500 errors will not respond a readable result
-	if resp.StatusCode >= http.StatusInternalServerError {
-		return fmt.Errorf("internal server error")
+func (m *Message) fields() url.Values {
+	v := url.Values{
+		"token": {m.app},
+		"user":  {m.rec},
 	}
-	_, err = io.ReadAll(resp.Body)
-	return fmt.Errorf("cannot read body: %w", err)
+	if len(m.device) > 0 {
+		v.Set("device", strings.Join(m.device, ","))
+	}
+	if m.priority != PriorityNormal {
+		v.Set("priority", strconv.Itoa(int(m.priority)))
+	}
+	if m.priority == PriorityEmergency {
+		setEmergency(v, m.retry, m.expire)
+	}
+	if m.sound != "" {
+		v.Set("sound", m.sound)
+	}
+	if m.url != "" {
+		v.Set("url", m.url)
+	}
+	if m.urlTitle != "" {
+		v.Set("url_title", m.urlTitle)
+	}
+	if m.ttl > 0 {
+		v.Set("ttl", strconv.Itoa(int(m.ttl/time.Second)))
+	}
+	for k, vs := range m.extra {
+		v[k] = vs
+	}
+	return v
+}
+
+// Check the message's options before sending
+func (m *Message) validate() error {
+	if m.err != nil {
+		return m.err
+	}
+	return m.checkURL()
+}
+
+func (m *Message) pushover(ctx context.Context, v url.Values, a *attachment, out any) error {
+	var err error
+	for i, part := range m.fit(v) {
+		if i > 0 {
+			a = nil // attach to the first part only
+		}
+		if err = m.post(ctx, part, a, out); err != nil {
+			break
+		}
+	}
+	m.recordResult(err)
+	if m.p != nil && m.p.Fallback != nil && permanent(err) {
+		// the send error is kept, a failing fallback is reported, too
+		err = errors.Join(err, m.p.Fallback(v.Get("title"), v.Get("message")))
+	}
+	return err
+}
+
+func (m *Message) post(ctx context.Context, v url.Values, a *attachment, out any) error {
+	contentType, body := "application/x-www-form-urlencoded", []byte(v.Encode())
+	if a != nil {
+		var err error
+		if contentType, body, err = a.multipart(v); err != nil {
+			return err
+		}
+	}
+	resp, rtt, err := m.p.callTimed(ctx, http.MethodPost, "/messages.json", contentType, body, out)
+	if resp != nil {
+		m.observeLatency(rtt)
+		m.observeLimit(resp.Header)
+		if r, ok := out.(*Result); ok && err == nil {
+			r.observe(resp.Header, time.Now())
+		}
+	}
+	return err
 }
 
 // Send a message with timeout. This function blocks until the message is successfully
 // sends and answer is received from the server.
 // If throttled, the functions returns immediately without trying to send the
-// message.
+// message. On timeout ErrConnectTimeout means the message was not delivered, after
+// ErrResponseTimeout it may have been delivered anyway.
 func (m *Message) SendAndWait(title, message string, timeout time.Duration) error {
-	return m.runThrottled(func() error { return m.pushover(title, message, timeout) })
+	ctx, cancel := timeoutContext(context.Background(), timeout)
+	defer cancel()
+	return m.sendWait(ctx, m.values(title, message), nil, nil)
+}
+
+// Send a message with timeout like SendAndWait(), aborting the request once cancel is
+// closed, for code not using contexts. An aborted send returns an error wrapping
+// context.Canceled, the message may have been delivered anyway.
+func (m *Message) SendAndWaitCancel(title, message string, timeout time.Duration, cancel <-chan struct{}) error {
+	ctx, stop := timeoutContext(context.Background(), timeout)
+	defer stop()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-cancel:
+			stop()
+		case <-done:
+		}
+	}()
+	return m.sendWait(ctx, m.values(title, message), nil, nil)
+}
+
+// Send a message and return the raw api response for debugging odd api behavior, prefer
+// SendAndWait() otherwise. The response is returned for any status, errors are only
+// returned if the message is not sent or the api cannot be reached. The call is not
+// retried. The caller must close the body, which is limited to 1MB.
+func (m *Message) SendRaw(ctx context.Context, title, message string) (*http.Response, error) {
+	v := m.values(title, message)
+	var resp *http.Response
+	err := m.gate(v, func() error {
+		ctx, sent := traceSent(ctx)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL+"/messages.json", strings.NewReader(v.Encode()))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		start := time.Now()
+		if resp, err = m.p.client().Do(req); err != nil {
+			return timeoutError(err, sent.Load())
+		}
+		m.observeLatency(time.Since(start))
+		m.observeLimit(resp.Header)
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(resp.Body, maxBody), resp.Body}
+		return nil
+	})
+	return resp, err
 }
 
 // Send message in background, return immediately. Network errors
 // will only occur in background and are silently dropped.
-// Only ErrThrottled is raised, if applicable
+// Only ErrThrottled is raised, if applicable. With Pushover.MaxConcurrentSends
+// the call waits while the limit of sends is in flight.
 func (m *Message) Send(title, message string) error {
-	return m.runThrottled(func() error { go m.pushover(title, message, 0); return nil })
+	return m.sendBackground(context.Background(), m.values(title, message), nil)
+}
+
+// Send message in background like Send(), cancelling the context aborts the send, e.g. on
+// shutdown. A cancelled send is no longer pending, see Flush().
+func (m *Message) SendCtx(ctx context.Context, title, message string) error {
+	return m.sendBackground(ctx, m.values(title, message), nil)
+}
+
+// Send a message written in markdown in background. Bold (**text**), italic (*text*)
+// and links ([text](url)) are converted to the HTML subset supported by pushover,
+// any other markup is sent literally. Errors are handled like in Send().
+func (m *Message) SendMarkdown(title, md string) error {
+	v := m.values(title, markdownToHTML(md))
+	v.Set("html", "1")
+	return m.sendBackground(context.Background(), v, nil)
+}
+
+// Send and wait for the response, decoded into out if not nil
+func (m *Message) sendWait(ctx context.Context, v url.Values, a *attachment, out any) error {
+	return m.gate(v, func() error { return m.pushover(ctx, v, a, out) })
+}
+
+// Send plain text in background, safe for user provided content. The message is sent as
+// HTML with HTML metacharacters escaped, so "<" and "&" are displayed literally instead
+// of being interpreted or stripped. Errors are handled like in Send().
+func (m *Message) SendText(title, message string) error {
+	v := m.values(title, html.EscapeString(message))
+	v.Set("html", "1")
+	return m.sendBackground(context.Background(), v, nil)
+}
+
+// Send the result of executing an html/template in background, as HTML. The template
+// escapes data fields, so only markup in the template itself is interpreted. The title
+// defaults like for an empty title in Send(). Errors are handled like in Send().
+func (m *Message) SendHTMLTemplate(tmpl *template.Template, data any) error {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return fmt.Errorf("cannot execute pushover template: %w", err)
+	}
+	v := m.values("", b.String())
+	v.Set("html", "1")
+	return m.sendBackground(context.Background(), v, nil)
+}
+
+// Error returned by background sends exceeding Pushover.MaxConcurrentSends with DropWhenBusy
+var ErrTooManySends = errors.New("pushover too many sends in flight")
+
+func (m *Message) sendBackground(ctx context.Context, v url.Values, a *attachment) error {
+	return m.sendBackgroundSlot(ctx, v, a, m.p != nil && m.p.DropWhenBusy)
+}
+
+// Like sendBackground(), with drop ErrTooManySends is returned instead of waiting for a
+// slot of Pushover.MaxConcurrentSends
+func (m *Message) sendBackgroundSlot(ctx context.Context, v url.Values, a *attachment, drop bool) error {
+	sem := m.p.sendSlots()
+	held, started := false, false
+	if sem != nil && drop {
+		// taken before the throttle, so a dropped send does not consume it
+		select {
+		case sem <- struct{}{}:
+			held = true
+		default:
+			return ErrTooManySends
+		}
+	}
+	err := m.gateAsync(v, func(done func(error)) error {
+		if sem != nil && !held {
+			if err := acquire(ctx, sem); err != nil {
+				done(err)
+				return err
+			}
+		}
+		started = true
+		st := m.state()
+		st.begin()
+		go func() {
+			defer st.end()
+			if sem != nil {
+				defer func() { <-sem }()
+			}
+			done(m.pushover(ctx, v, a, nil))
+		}()
+		return nil
+	})
+	if held && !started {
+		<-sem
+	}
+	return err
+}
+
+// Semaphore limiting background sends, nil if unlimited
+func (p *Pushover) sendSlots() chan struct{} {
+	if p == nil || p.MaxConcurrentSends <= 0 {
+		return nil
+	}
+	st := p.state()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.sends == nil {
+		st.sends = make(chan struct{}, p.MaxConcurrentSends)
+	}
+	return st.sends
+}
+
+func (st *msgState) begin() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.pending.Add(1) == 1 {
+		st.idle = make(chan struct{})
+	}
+}
+
+func (st *msgState) end() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.pending.Add(-1) == 0 {
+		close(st.idle)
+	}
+}
+
+// Number of background sends of this message still in flight.
+func (m *Message) Pending() int { return int(m.state().pending.Load()) }
+
+// Wait for all background sends of this message to finish, e.g. before a program exits.
+// Returns the context error if the context is done first, sends keep running then.
+func (m *Message) Flush(ctx context.Context) error {
+	st := m.state()
+	st.mu.Lock()
+	if st.pending.Load() == 0 {
+		st.mu.Unlock()
+		return nil
+	}
+	idle := st.idle
+	st.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -1,15 +1,229 @@
 package pushover
 
 import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
 	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
+//go:embed sample.json
+var sampleFS embed.FS
+
 func TestLoad(t *testing.T) {
 	load(t)
 }
 
+func TestLoadReader(t *testing.T) {
+	p, err := LoadReader(strings.NewReader(`{"app": {"a1": "app1"}, "rec": {"r1": "rec1"}}`))
+	if err != nil {
+		t.Fatalf("cannot load config from reader: %s", err)
+	}
+	if !p.HasApp("a1") || !p.HasRec("r1") {
+		t.Errorf("cannot find keys a1 and r1 in config read from reader")
+	}
+	if _, err := LoadReader(strings.NewReader(`{"app": `)); err == nil {
+		t.Errorf("loaded truncated config without error")
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	p, err := LoadFS(sampleFS, "sample.json")
+	if err != nil {
+		t.Fatalf("cannot load embedded config: %s", err)
+	}
+	if !p.HasApp("a1", "a2") || !p.HasRec("r1", "r2") {
+		t.Errorf("cannot find keys in embedded config")
+	}
+	if _, err := LoadFS(sampleFS, "missing.json"); err == nil {
+		t.Errorf("loaded missing embedded file without error")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	_, err := Load(filepath.Join(dir, "missing.json"))
+	if !errors.Is(err, ErrConfigNotFound) || errors.Is(err, ErrConfigInvalid) {
+		t.Errorf("missing config returned %v, want ErrConfigNotFound", err)
+	}
+	if _, err := LoadFS(sampleFS, "missing.json"); !errors.Is(err, ErrConfigNotFound) {
+		t.Errorf("missing embedded config returned %v, want ErrConfigNotFound", err)
+	}
+
+	broken := filepath.Join(dir, "broken.json")
+	if err := os.WriteFile(broken, []byte(`{"app": [`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err = Load(broken)
+	if !errors.Is(err, ErrConfigInvalid) || errors.Is(err, ErrConfigNotFound) {
+		t.Errorf("broken config returned %v, want ErrConfigInvalid", err)
+	}
+	if _, err := LoadReader(strings.NewReader(`{"rec": {"r1": 42}}`)); !errors.Is(err, ErrConfigInvalid) {
+		t.Errorf("config with bad receiver returned %v, want ErrConfigInvalid", err)
+	}
+}
+
+func TestConfigAliases(t *testing.T) {
+	p, err := LoadReader(strings.NewReader(`{
+		"App": {"a1": "app1"},
+		"applications": {"a1": "ignored", "a2": "app2"},
+		"receivers": {"r1": "rec1"},
+		"users": {"r2": {"key": "rec2", "devices": ["phone"]}},
+		"retries": 2
+	}`))
+	if err != nil {
+		t.Fatalf("cannot load config with aliases: %s", err)
+	}
+	if p.App["a1"] != "app1" || p.App["a2"] != "app2" || p.Rec["r1"].Key != "rec1" || p.Rec["r2"].Devices[0] != "phone" {
+		t.Errorf("aliases loaded app=%v, rec=%v", p.App, p.Rec)
+	}
+	if p.Retries != 2 || p.Version != configVersion {
+		t.Errorf("aliased config loaded retries=%d, version=%d", p.Retries, p.Version)
+	}
+}
+
+func TestLoadStdin(t *testing.T) {
+	defer func(r io.Reader) { stdin = r }(stdin)
+	stdin = strings.NewReader(`{"app": {"a1": "app1"}, "rec": {"r1": "rec1"}}`)
+	p, err := Load("-")
+	if err != nil || p.App["a1"] != "app1" || p.Rec["r1"].Key != "rec1" {
+		t.Errorf("loaded %+v from stdin, err=%v", p, err)
+	}
+	stdin = strings.NewReader(`{"app": {"a1": "app1"}}`)
+	if _, err := Load("-", RequireReceivers()); !errors.Is(err, ErrConfigInvalid) {
+		t.Errorf("options not applied to stdin config, err=%v", err)
+	}
+}
+
+func TestRequireReceivers(t *testing.T) {
+	const config = `{"app": {"a1": "app1"}, "rec": {}}`
+	if _, err := LoadReader(strings.NewReader(config)); err != nil {
+		t.Errorf("cannot load app-only config: %s", err)
+	}
+	if _, err := LoadReader(strings.NewReader(config), RequireReceivers()); !errors.Is(err, ErrConfigInvalid) {
+		t.Errorf("config without receivers returned %v, want ErrConfigInvalid", err)
+	}
+	if _, err := LoadFS(sampleFS, "sample.json", RequireReceivers()); err != nil {
+		t.Errorf("cannot load config with receivers: %s", err)
+	}
+}
+
+func TestConfigEnv(t *testing.T) {
+	t.Setenv("PUSHOVER_TEST_TOKEN", "app-from-env")
+	t.Setenv("PUSHOVER_TEST_USER", "rec-from-env")
+	p, err := LoadReader(strings.NewReader(`{
+		"app": {"home": "${PUSHOVER_TEST_TOKEN}", "plain": "app1"},
+		"rec": {"me": {"key": "${PUSHOVER_TEST_USER}", "devices": ["phone"]}}
+	}`))
+	if err != nil {
+		t.Fatalf("cannot load config with environment variables: %s", err)
+	}
+	if p.App["home"] != "app-from-env" || p.App["plain"] != "app1" || p.Rec["me"].Key != "rec-from-env" || len(p.Rec["me"].Devices) != 1 {
+		t.Errorf("expanded config app=%v, rec=%v", p.App, p.Rec)
+	}
+
+	_, err = LoadReader(strings.NewReader(`{"app": {"home": "${PUSHOVER_TEST_UNSET}"}}`))
+	if !errors.Is(err, ErrConfigInvalid) || !strings.Contains(err.Error(), "PUSHOVER_TEST_UNSET") {
+		t.Errorf("unset variable returned %v, want ErrConfigInvalid naming the variable", err)
+	}
+}
+
+func TestStrictPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions not enforced on windows")
+	}
+	fname := filepath.Join(t.TempDir(), "pushover.json")
+	if err := os.WriteFile(fname, []byte(`{"app": {"a1": "app1"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(fname); err != nil {
+		t.Errorf("cannot load readable config without strict permissions: %s", err)
+	}
+	if _, err := Load(fname, StrictPermissions()); !errors.Is(err, ErrInsecurePermissions) {
+		t.Errorf("readable config returned %v, want ErrInsecurePermissions", err)
+	}
+	if err := os.Chmod(fname, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(fname, StrictPermissions()); err != nil {
+		t.Errorf("cannot load private config with strict permissions: %s", err)
+	}
+}
+
+func TestConfigVersion(t *testing.T) {
+	for _, tc := range []struct {
+		name, config string
+		ok           bool
+	}{
+		{"v0", `{"app": {"a1": "app1"}, "rec": {"r1": "rec1"}}`, true},
+		{"v1", `{"version": 1, "app": {"a1": "app1"}, "rec": {"r1": {"key": "rec1", "devices": ["phone"]}}}`, true},
+		{"v2", `{"version": 2, "app": {"a1": "app1"}, "rec": {"r1": "rec1"}}`, false},
+	} {
+		p, err := LoadReader(strings.NewReader(tc.config))
+		if !tc.ok {
+			if !errors.Is(err, ErrConfigInvalid) {
+				t.Errorf("%s config returned %v, want ErrConfigInvalid", tc.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("cannot load %s config: %s", tc.name, err)
+			continue
+		}
+		if p.Version != configVersion || !p.HasApp("a1") || p.Rec["r1"].Key != "rec1" {
+			t.Errorf("%s config migrated to %+v", tc.name, p)
+		}
+	}
+
+	p, err := LoadReader(strings.NewReader(`{}`))
+	if err != nil || p.App == nil || p.Rec == nil {
+		t.Errorf("empty config migrated to %+v, err=%v", p, err)
+	}
+}
+
+func ExampleLoadReader() {
+	// With an embed.FS, a single binary can ship a baked-in config:
+	//
+	//	//go:embed sample.json
+	//	var sampleFS embed.FS
+	f, err := sampleFS.Open("sample.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	p, err := LoadReader(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(p.HasApp("a1"), p.HasRec("r2"))
+	// Output: true true
+}
+
+func ExampleLoadFS() {
+	p, err := LoadFS(sampleFS, "sample.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(p.HasApp("a1", "a2"))
+	// Output: true
+}
+
 func TestHasAppAndKey(t *testing.T) {
 	p := load(t)
 	if !p.HasApp("a1", "a2") {
@@ -36,39 +250,999 @@ func TestMessage(t *testing.T) {
 	_ = message(t)
 }
 
-func TestThrottle(t *testing.T) {
+func TestReceiverDevices(t *testing.T) {
+	p := load(t)
+	m := p.MustMessage("a1", "work")
+	if got := m.values("t", "m").Get("device"); got != "work-phone" {
+		t.Errorf("device=%q, want configured work-phone", got)
+	}
+	if m.rec != "rec3" {
+		t.Errorf("receiver key=%q, want rec3", m.rec)
+	}
+	m.Set(WithDevice("iphone", "ipad"))
+	if got := m.values("t", "m").Get("device"); got != "iphone,ipad" {
+		t.Errorf("device=%q, want overridden iphone,ipad", got)
+	}
+	m = p.MustMessage("a1", "r1")
+	if v := m.values("t", "m"); v.Has("device") {
+		t.Errorf("device=%q set for receiver without devices", v.Get("device"))
+	}
+}
+
+func TestMessageApp(t *testing.T) {
+	p := load(t)
+	if _, err := p.MessageApp("a1"); err == nil {
+		t.Errorf("created message without default receiver")
+	}
+	p.SetDefaultReceiver("work")
+	m, err := p.MessageApp("a1", WithSound("siren"))
+	if err != nil {
+		t.Fatalf("cannot create message for default receiver: %s", err)
+	}
+	if got := m.values("t", "m").Encode(); got != "device=work-phone&message=m&sound=siren&title=t&token=app1&user=rec3" {
+		t.Errorf("default receiver message posts %s", got)
+	}
+	p.SetDefaultReceiver("missing")
+	if _, err := p.MessageApp("a1"); err == nil {
+		t.Errorf("created message for unknown default receiver")
+	}
+}
+
+func TestTo(t *testing.T) {
+	p := load(t)
+	m := p.MustMessage("a1", "work")
+	m.Throttle(time.Hour)
+	m.runThrottled(func() error { return nil })
+
+	c, err := m.To("oncall")
+	if err != nil {
+		t.Fatalf("cannot retarget message: %s", err)
+	}
+	v := c.values("t", "m")
+	if v.Get("user") != "oncall" || v.Get("token") != "app1" || v.Has("device") {
+		t.Errorf("retargeted message posts %v", v)
+	}
+	if c.throttle != time.Hour {
+		t.Errorf("throttle=%s not kept by To()", c.throttle)
+	}
+	if err := c.runThrottled(func() error { return nil }); err != nil {
+		t.Errorf("retargeted message shares throttle state, err=%s", err)
+	}
+	if m.rec != "rec3" {
+		t.Errorf("original receiver changed to %s", m.rec)
+	}
+	if _, err := m.To(""); err == nil {
+		t.Errorf("retargeted message to empty receiver without error")
+	}
+
+	// the name of a configured receiver is used for pair throttles and latency
+	p.ThrottlePair("a1", "work", time.Hour)
+	w := p.MustMessage("a1", "work")
+	w.runThrottled(func() error { return nil })
+	c, _ = p.MustMessage("a1", "r1").To("rec3")
+	if c.recName != "work" {
+		t.Errorf("message retargeted to rec3 has receiver name %q, want work", c.recName)
+	}
+	if err := c.runThrottled(func() error { return nil }); err != ErrThrottled {
+		t.Errorf("pair throttle not applied to retargeted message, err=%v", err)
+	}
+	if c, _ = m.To("rec1"); c.recName != "" {
+		t.Errorf("message retargeted to key of several receivers has receiver name %q", c.recName)
+	}
+}
+
+func TestFresh(t *testing.T) {
+	p := load(t)
+	template := p.MustMessage("a1", "work")
+	template.Throttle(time.Hour)
+	template.runThrottled(func() error { return nil })
+
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*2)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(m Message) {
+			defer wg.Done()
+			if m.throttle != time.Hour || m.values("t", "m").Get("device") != "work-phone" {
+				errs <- fmt.Errorf("fresh message lost options")
+			}
+			if err := m.runThrottled(func() error { return nil }); err != nil {
+				errs <- fmt.Errorf("first send of worker throttled: %w", err)
+			}
+			if err := m.runThrottled(func() error { return nil }); err != ErrThrottled {
+				errs <- fmt.Errorf("second send of worker not throttled, err=%v", err)
+			}
+		}(template.Fresh())
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if err := template.runThrottled(func() error { return nil }); err != ErrThrottled {
+		t.Errorf("template throttle state reset by workers, err=%v", err)
+	}
+}
+
+func TestOptions(t *testing.T) {
 	m := message(t)
+	m.Set(
+		WithPriority(PriorityEmergency),
+		WithRetry(time.Minute, time.Hour),
+		WithSound("siren"),
+		WithURL("https://example.com", "Dashboard"),
+		WithTTL(90*time.Second),
+	)
+	want := "expire=3600&message=m&priority=2&retry=60&sound=siren&title=t&token=app1&ttl=90&url=https%3A%2F%2Fexample.com&url_title=Dashboard&user=rec1"
+	if got := m.values("t", "m").Encode(); got != want {
+		t.Errorf("message posts %s, want %s", got, want)
+	}
+}
+
+func TestMustMessageOptions(t *testing.T) {
+	p := load(t)
+	m := p.MustMessage("a1", "r1", WithPriority(PriorityHigh), WithSound("siren"))
+	if got := m.values("t", "m").Encode(); got != "message=m&priority=1&sound=siren&title=t&token=app1&user=rec1" {
+		t.Errorf("message created with options posts %s", got)
+	}
+}
+
+func TestSourcePrefix(t *testing.T) {
+	posted := make(chan url.Values, 10)
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		posted <- r.PostForm
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	p := load(t)
+	p.SourcePrefix = "[web01] "
+	m := p.MustMessage("a1", "r1")
+	if err := m.SendAndWait("Disk", "full", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if v := <-posted; v.Get("title") != "[web01] Disk" || v.Get("message") != "full" {
+		t.Errorf("send posted title %q, message %q", v.Get("title"), v.Get("message"))
+	}
+	m.Send("", "untitled")
+	if v := <-posted; v.Get("title") != "" || v.Get("message") != "[web01] untitled" {
+		t.Errorf("untitled send posted title %q, message %q", v.Get("title"), v.Get("message"))
+	}
+	p.Broadcast(context.Background(), "a1", []string{"r2"}, "Deploy", "done")
+	if v := <-posted; v.Get("title") != "[web01] Deploy" {
+		t.Errorf("broadcast posted title %q", v.Get("title"))
+	}
+}
+
+func TestAnnotatePriority(t *testing.T) {
+	posted := make(chan url.Values, 1)
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/receipts/") {
+			fmt.Fprint(w, `{"status":1,"acknowledged":1}`)
+			return
+		}
+		r.ParseForm()
+		posted <- r.PostForm
+		fmt.Fprint(w, `{"status":1,"request":"req","receipt":"rcpt"}`)
+	})
+	p := load(t)
+	p.AnnotatePriority = true
+	p.SourcePrefix = "[web01] "
+	for _, tc := range []struct {
+		priority       Priority
+		title, message string
+	}{
+		{PriorityLowest, "[web01] Disk", "full"},
+		{PriorityNormal, "[web01] Disk", "full"},
+		{PriorityHigh, "⚠️ [web01] Disk", "full"},
+		{PriorityEmergency, "🚨 [web01] Disk", "full"},
+	} {
+		m := p.MustMessage("a1", "r1", WithPriority(tc.priority))
+		m.SendAndWait("Disk", "full", time.Second)
+		if v := <-posted; v.Get("title") != tc.title || v.Get("message") != tc.message {
+			t.Errorf("priority %d posts title %q, message %q", tc.priority, v.Get("title"), v.Get("message"))
+		}
+	}
+
+	// the marker follows the priority actually sent
+	normal, high := p.MustMessage("a1", "r1"), p.MustMessage("a1", "r1", WithPriority(PriorityHigh))
+	normal.SendLevel(LevelCritical, "Disk", "full")
+	if v := <-posted; v.Get("priority") != "2" || v.Get("title") != "🚨 [web01] Disk" {
+		t.Errorf("critical level posts priority %s, title %q", v.Get("priority"), v.Get("title"))
+	}
+	high.SendLevel(slog.LevelInfo, "Disk", "full")
+	if v := <-posted; v.Get("title") != "[web01] Disk" {
+		t.Errorf("info level posts priority %s, title %q", v.Get("priority"), v.Get("title"))
+	}
+	normal.Flush(context.Background())
+	high.Flush(context.Background())
+	normal.SendEmergencyAndAwaitAck(context.Background(), "Disk", "full", 0, 0, time.Millisecond)
+	if v := <-posted; v.Get("title") != "🚨 [web01] Disk" {
+		t.Errorf("emergency and await ack posts title %q", v.Get("title"))
+	}
+
+	clock := &fakeClock{t: time.Date(2024, 7, 15, 3, 0, 0, 0, time.UTC)}
+	p.clock = clock
+	p.QuietHours = &QuietHours{DailyWindow: DailyWindow{Start: "22:00", End: "07:00", TimeZone: "UTC"}}
+	high = p.MustMessage("a1", "r1", WithPriority(PriorityHigh))
+	high.SendAndWait("Disk", "full", time.Second)
+	if v := <-posted; v.Get("priority") != "-1" || v.Get("title") != "[web01] Disk" {
+		t.Errorf("quiet hours post priority %s, title %q", v.Get("priority"), v.Get("title"))
+	}
+	p.QuietHours = nil
+	p.BusinessHours = &BusinessHours{DailyWindow: DailyWindow{Start: "09:00", End: "17:00", TimeZone: "UTC"}}
+	high = p.MustMessage("a1", "r1", WithPriority(PriorityHigh))
+	high.SendAndWait("Disk", "full", time.Second)
+	if v := <-posted; v.Get("priority") != "2" || v.Get("title") != "🚨 [web01] Disk" {
+		t.Errorf("outside business hours posts priority %s, title %q", v.Get("priority"), v.Get("title"))
+	}
+
+	p.BusinessHours = nil
+	p.PriorityMarkers = map[Priority]string{PriorityEmergency: "[!!] "}
+	m := p.MustMessage("a1", "r1", WithPriority(PriorityEmergency), NoTitle())
+	m.SendAndWait("Disk", "full", time.Second)
+	if v := <-posted; v.Get("message") != "[!!] [web01] full" {
+		t.Errorf("custom marker posts message %q", v.Get("message"))
+	}
+	m = p.MustMessage("a1", "r1", WithPriority(PriorityHigh))
+	m.SendAndWait("Disk", "full", time.Second)
+	if v := <-posted; v.Get("title") != "[web01] Disk" {
+		t.Errorf("priority without custom marker posts title %q", v.Get("title"))
+	}
+}
+
+func TestDefaultTitleFromProcess(t *testing.T) {
+	p := load(t)
+	m := p.MustMessage("a1", "r1")
+	if got := m.values("", "m").Get("title"); got != "" {
+		t.Errorf("empty title replaced by %q without DefaultTitleFromProcess", got)
+	}
+	p.DefaultTitleFromProcess = true
+	if got := m.values("", "m").Get("title"); got != filepath.Base(os.Args[0]) || got == "" {
+		t.Errorf("empty title replaced by %q, want program name %s", got, filepath.Base(os.Args[0]))
+	}
+	if got := m.values("Backup", "m").Get("title"); got != "Backup" {
+		t.Errorf("title replaced by %q", got)
+	}
+}
+
+func TestFirst(t *testing.T) {
+	p := load(t)
+	for i := 0; i < 10; i++ { // map order differs between iterations
+		if app, rec, ok := p.First(); app != "a1" || rec != "r1" || !ok {
+			t.Fatalf("first is %s/%s, ok=%t, want a1/r1", app, rec, ok)
+		}
+	}
+	p.Rec = nil
+	if _, _, ok := p.First(); ok {
+		t.Errorf("first ok without receivers")
+	}
+}
+
+func TestSanitizeInput(t *testing.T) {
+	m := message(t)
+	v := m.values("Disk\x00 full\x1b[0m", "line 1\r\nline 2\x07\tend\x7f")
+	if v.Get("title") != "Disk full[0m" || v.Get("message") != "line 1\nline 2end" {
+		t.Errorf("sanitized title %q, message %q", v.Get("title"), v.Get("message"))
+	}
+	m.Set(SanitizeInput(false))
+	if v := m.values("a\x00b", "c\x07"); v.Get("title") != "a\x00b" || v.Get("message") != "c\x07" {
+		t.Errorf("unsanitized title %q, message %q", v.Get("title"), v.Get("message"))
+	}
+}
+
+func TestNoTitle(t *testing.T) {
+	posted := make(chan url.Values, 10)
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		posted <- r.PostForm
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	p := load(t)
+	p.DefaultTitleFromProcess = true
+	m := p.MustMessage("a1", "r1", NoTitle(), WithTruncate(SplitLong))
+	if err := m.SendAndWait("ignored", "app name only", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if v := <-posted; v.Has("title") || v.Get("message") != "app name only" {
+		t.Errorf("posted %v, want message without title field", v)
+	}
+	if err := m.SendAndWait("", strings.Repeat("x", maxMessage+1), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if v := <-posted; v.Has("title") {
+			t.Errorf("part %d posted title %q", i+1, v.Get("title"))
+		}
+	}
+}
+
+func TestWithExtra(t *testing.T) {
+	m := message(t)
+	m.Set(WithPriority(PriorityHigh), WithExtra("priority", "-1"), WithExtra("new_param", "x"))
+	v := m.values("t", "m")
+	if v.Get("priority") != "-1" || v.Get("new_param") != "x" {
+		t.Errorf("extra fields not posted: %v", v)
+	}
+	c := m.Fresh()
+	c.Set(WithExtra("b", "2"))
+	if m.Set(); m.values("t", "m").Has("b") || c.values("t", "m").Get("new_param") != "x" {
+		t.Errorf("extra field of a copy changed the original message")
+	}
+
+	for _, key := range []string{"token", "user", "message", "title"} {
+		m := message(t)
+		m.Set(WithExtra(key, "x"))
+		if err := m.Send("t", "m"); err == nil || m.values("t", "m").Get(key) == "x" {
+			t.Errorf("extra %s overrides core field", key)
+		}
+	}
+}
+
+func TestCustomSchemes(t *testing.T) {
+	m := message(t)
+	m.Set(WithURL("pushover://open", "Open app"))
+	if err := m.validate(); err == nil {
+		t.Errorf("pushover:// url accepted without AllowCustomSchemes")
+	}
+	m.Set(AllowCustomSchemes())
+	if err := m.validate(); err != nil {
+		t.Errorf("pushover:// url rejected with AllowCustomSchemes: %s", err)
+	}
+	for _, u := range []string{"https://example.com/x", "http://example.com"} {
+		m := message(t)
+		if m.Set(WithURL(u, "")); m.validate() != nil {
+			t.Errorf("url %s rejected", u)
+		}
+	}
+	m.Set(WithURL("example.com/no-scheme", ""))
+	if err := m.validate(); err == nil {
+		t.Errorf("url without scheme accepted")
+	}
+}
+
+func TestEmergencyDefaults(t *testing.T) {
+	m := message(t)
+	m.Set(WithPriority(PriorityEmergency))
+	v := m.values("t", "m")
+	if v.Get("retry") != "60" || v.Get("expire") != "3600" {
+		t.Errorf("emergency message without retry posts retry=%s expire=%s, want defaults", v.Get("retry"), v.Get("expire"))
+	}
+
+	defer func(r, e time.Duration) { DefaultEmergencyRetry, DefaultEmergencyExpire = r, e }(DefaultEmergencyRetry, DefaultEmergencyExpire)
+	DefaultEmergencyRetry, DefaultEmergencyExpire = 30*time.Second, 2*time.Hour
+	m.Set(WithRetry(0, 10*time.Minute))
+	v = m.values("t", "m")
+	if v.Get("retry") != "30" || v.Get("expire") != "600" {
+		t.Errorf("emergency message posts retry=%s expire=%s, want 30 and explicit 600", v.Get("retry"), v.Get("expire"))
+	}
+}
+
+func TestEmergencyLimits(t *testing.T) {
+	for _, tc := range []struct {
+		retry, expire time.Duration
+		want          string // part of the error, empty for valid
+	}{
+		{0, 0, ""},
+		{30 * time.Second, 10800 * time.Second, ""},
+		{29 * time.Second, 0, "retry 29s below minimum of 30s"},
+		{30*time.Second + time.Millisecond, 0, "retry 30.001s is not whole seconds"},
+		{0, 10801 * time.Second, "expire 3h0m1s out of range"},
+		{0, 1500 * time.Millisecond, "expire 1.5s is not whole seconds"},
+		{0, -time.Second, "expire -1s out of range"},
+	} {
+		m := message(t)
+		m.Set(WithPriority(PriorityEmergency), WithRetry(tc.retry, tc.expire))
+		err := m.validate()
+		if tc.want == "" && err != nil || tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)) {
+			t.Errorf("retry %s, expire %s returned %v, want %q", tc.retry, tc.expire, err, tc.want)
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	p := load(t)
+	m := p.MustMessage("a1", "work")
+	m.Set(WithPriority(PriorityHigh), WithSound("siren"), WithURL("https://example.com", "x"), WithTTL(time.Minute))
+	m.Throttle(time.Hour)
+	m.BackoffThrottle(time.Minute, time.Hour)
+	m.recordResult(errors.New("failed"))
+	m.runThrottled(func() error { return nil })
+
+	m.Reset()
+	if got := m.values("t", "m").Encode(); got != "message=m&title=t&token=app1&user=rec3" {
+		t.Errorf("reset message posts %s", got)
+	}
+	if m.throttle != 0 || m.st.backoff != 0 || !m.LastSent().IsZero() {
+		t.Errorf("reset message keeps throttle=%s, backoff=%s, last sent=%s", m.throttle, m.st.backoff, m.LastSent())
+	}
+	if err := m.runThrottled(func() error { return nil }); err != nil {
+		t.Errorf("reset message throttled, err=%s", err)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)}
+	p := load(t)
+	p.clock = clock
+	m := p.MustMessage("a1", "r1")
 	var counter int
 
 	m.Throttle(0)
+	if m.ThrottleInterval() != 0 {
+		t.Errorf("throttle interval %s, want 0", m.ThrottleInterval())
+	}
 	counter = 0
 	count := func() error { counter++; return nil }
 	for i := 0; i < 10; i++ {
 		if err := m.runThrottled(count); err != nil {
 			t.Errorf("runner returned error, throttle=%s, err=%s", m.throttle, err)
 		}
-		time.Sleep(10 * time.Millisecond)
+		clock.Advance(10 * time.Millisecond)
 	}
 	if counter != 10 {
 		t.Errorf("throttled, count=%d, want 10", counter)
 	}
 
 	m.Throttle(time.Second)
+	if m.ThrottleInterval() != time.Second {
+		t.Errorf("throttle interval %s, want 1s", m.ThrottleInterval())
+	}
 	counter = 0
 	m.runThrottled(count)
 	for i := 0; i < 10; i++ {
+		clock.Advance(10 * time.Millisecond)
 		switch err := m.runThrottled(count); {
 		case err == nil:
 			t.Error("got NIL error, should have throttled")
 		case err != ErrThrottled:
 			t.Errorf("runner returned error, throttle=%s, err=%s", m.throttle, err)
 		}
-		time.Sleep(10 * time.Millisecond)
 	}
 	if counter != 1 {
-		t.Errorf("not throttled, count=%d, want 10", counter)
+		t.Errorf("not throttled, count=%d, want 1", counter)
+	}
+
+	clock.Advance(time.Second - 100*time.Millisecond)
+	if err := m.runThrottled(count); err != nil || counter != 2 {
+		t.Errorf("throttle period passed but still throttled, count=%d, err=%v", counter, err)
+	}
+}
+
+func TestWouldThrottle(t *testing.T) {
+	p := load(t)
+	m := p.MustMessage("a1", "r1")
+	m.Throttle(time.Hour)
+	for i := 0; i < 3; i++ {
+		if m.WouldThrottle() || !m.LastSent().IsZero() {
+			t.Fatalf("check %d throttled or advanced the timer to %s", i, m.LastSent())
+		}
+	}
+	m.runThrottled(func() error { return nil })
+	sent := m.LastSent()
+	for i := 0; i < 3; i++ {
+		if !m.WouldThrottle() || m.LastSent() != sent {
+			t.Fatalf("check %d after send not throttled or moved the timer", i)
+		}
+	}
+
+	p.ThrottlePair("a1", "r2", time.Hour)
+	other := p.MustMessage("a1", "r2")
+	if other.WouldThrottle() || other.WouldThrottle() {
+		t.Errorf("unused pair throttle would throttle")
+	}
+	if err := other.runThrottled(func() error { return nil }); err != nil {
+		t.Errorf("checks consumed the pair throttle: %s", err)
+	}
+	if fresh := p.MustMessage("a1", "r2"); !fresh.WouldThrottle() {
+		t.Errorf("fresh message of throttled pair would not throttle")
+	}
+}
+
+func TestLastSent(t *testing.T) {
+	m := message(t)
+	if !m.LastSent().IsZero() {
+		t.Errorf("new message has last sent time %s", m.LastSent())
+	}
+	m.Throttle(time.Hour)
+	before := time.Now()
+	m.runThrottled(func() error { return fmt.Errorf("failed") })
+	sent := m.LastSent()
+	if sent.Before(before) {
+		t.Errorf("failed attempt not recorded, last sent=%s", sent)
+	}
+	if m.runThrottled(func() error { return nil }) != ErrThrottled || m.LastSent() != sent {
+		t.Errorf("throttled message changed last sent time")
+	}
+	m.ResetThrottle()
+	if !m.LastSent().IsZero() {
+		t.Errorf("last sent=%s after reset, want zero", m.LastSent())
+	}
+}
+
+func TestThrottleOnSuccess(t *testing.T) {
+	var fail atomic.Bool
+	release := make(chan struct{}, 1)
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		<-release
+		if fail.Load() {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":0,"errors":["user key is invalid"]}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	m := message(t)
+	m.Throttle(time.Hour)
+	m.ThrottleOnSuccess(true)
+
+	fail.Store(true)
+	if err := m.Send("title", "fails"); err != nil {
+		t.Fatalf("cannot send message: %s", err)
+	}
+	if err := m.Send("title", "in flight"); err != ErrThrottled {
+		t.Errorf("send while another one is in flight returned %v, want ErrThrottled", err)
+	}
+	release <- struct{}{}
+	m.Flush(context.Background())
+	if !m.LastSent().IsZero() || m.WouldThrottle() {
+		t.Errorf("failed send advanced the throttle, last sent=%s", m.LastSent())
+	}
+
+	fail.Store(false)
+	if err := m.Send("title", "retry"); err != nil {
+		t.Fatalf("retry after failed send returned %v", err)
+	}
+	release <- struct{}{}
+	m.Flush(context.Background())
+	if m.LastSent().IsZero() || m.Send("title", "again") != ErrThrottled {
+		t.Errorf("successful send did not advance the throttle")
+	}
+
+	m.ResetThrottle()
+	m.ThrottleOnSuccess(false)
+	fail.Store(true)
+	m.Send("title", "fails")
+	release <- struct{}{}
+	m.Flush(context.Background())
+	if m.Send("title", "next") != ErrThrottled {
+		t.Errorf("failed send did not consume the throttle by default")
+	}
+}
+
+func TestThrottlePair(t *testing.T) {
+	p := load(t)
+	p.ThrottlePair("a1", "r1", time.Second)
+	count := func() error { return nil }
+
+	for i := 0; i < 3; i++ {
+		m := p.MustMessage("a1", "r1")
+		switch err := m.runThrottled(count); {
+		case i == 0 && err != nil:
+			t.Errorf("first message for pair a1/r1 returned error: %s", err)
+		case i > 0 && err != ErrThrottled:
+			t.Errorf("fresh message #%d for pair a1/r1 not throttled, err=%v", i, err)
+		}
+	}
+	m := p.MustMessage("a1", "r2")
+	if err := m.runThrottled(count); err != nil {
+		t.Errorf("message for unthrottled pair a1/r2 returned error: %s", err)
+	}
+
+	p.ThrottlePair("a1", "r1", 0)
+	m = p.MustMessage("a1", "r1")
+	if err := m.runThrottled(count); err != nil {
+		t.Errorf("message for released pair a1/r1 returned error: %s", err)
+	}
+}
+
+func TestThrottleStats(t *testing.T) {
+	m := message(t)
+	m.Throttle(time.Hour)
+	noop := func() error { return nil }
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		c := m // copies share the counters
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.runThrottled(noop)
+		}()
+	}
+	wg.Wait()
+	if allowed, dropped := m.ThrottleStats(); allowed != 1 || dropped != 19 {
+		t.Errorf("throttle stats allowed %d, dropped %d, want 1 and 19", allowed, dropped)
+	}
+	m.ResetThrottle()
+	m.runThrottled(noop)
+	if allowed, dropped := m.ThrottleStats(); allowed != 2 || dropped != 19 {
+		t.Errorf("throttle stats after reset allowed %d, dropped %d, want 2 and 19", allowed, dropped)
+	}
+}
+
+func TestBackoffThrottle(t *testing.T) {
+	failing := true
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	m := message(t)
+	m.BackoffThrottle(time.Minute, 4*time.Minute)
+
+	for i, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 4 * time.Minute} {
+		if err := m.SendAndWait("title", "message", time.Second); err == nil {
+			t.Fatalf("send #%d to failing server returned no error", i)
+		}
+		if got := m.st.backoff; got != want {
+			t.Errorf("backoff after %d failures=%s, want %s", i+1, got, want)
+		}
+		if err := m.SendAndWait("title", "message", time.Second); err != ErrThrottled {
+			t.Errorf("send during backoff not throttled, err=%v", err)
+		}
+		m.ResetThrottle()
+	}
+
+	failing = false
+	if err := m.SendAndWait("title", "message", time.Second); err != nil {
+		t.Fatalf("send to recovered server returned error: %s", err)
+	}
+	if m.st.backoff != 0 {
+		t.Errorf("backoff=%s after successful send, want 0", m.st.backoff)
+	}
+	if err := m.SendAndWait("title", "message", time.Second); err != nil {
+		t.Errorf("send after recovery returned error: %s", err)
+	}
+}
+
+func TestBackoffThrottleOnSuccess(t *testing.T) {
+	var calls atomic.Int32
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	p := load(t)
+	clock := &fakeClock{t: time.Now()}
+	p.clock = clock
+	m := p.MustMessage("a1", "r1")
+	m.ThrottleOnSuccess(true)
+	m.BackoffThrottle(time.Minute, 4*time.Minute)
+	for i := 0; i < 3; i++ {
+		m.SendAndWait("title", "message", time.Second)
+	}
+	if calls.Load() != 1 || !m.LastSent().IsZero() {
+		t.Errorf("failing server got %d calls during backoff, last sent=%s", calls.Load(), m.LastSent())
+	}
+	clock.Advance(time.Minute)
+	if err := m.SendAndWait("title", "message", time.Second); err == nil || err == ErrThrottled || calls.Load() != 2 {
+		t.Errorf("send after backoff returned %v with %d calls", err, calls.Load())
+	}
+}
+
+func TestPendingAndFlush(t *testing.T) {
+	release := make(chan struct{})
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	m := message(t)
+	if err := m.Flush(context.Background()); err != nil || m.Pending() != 0 {
+		t.Fatalf("flush of idle message: pending=%d, err=%v", m.Pending(), err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := m.Send("title", "message"); err != nil {
+			t.Fatalf("cannot send message: %s", err)
+		}
+	}
+	if m.Pending() != 3 {
+		t.Errorf("pending=%d, want 3", m.Pending())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := m.Flush(ctx); err != context.DeadlineExceeded {
+		t.Errorf("flush of blocked sends returned %v, want deadline exceeded", err)
+	}
+
+	close(release)
+	if err := m.Flush(context.Background()); err != nil {
+		t.Errorf("flush returned error: %s", err)
+	}
+	if m.Pending() != 0 {
+		t.Errorf("pending=%d after flush, want 0", m.Pending())
+	}
+}
+
+func TestMaxConcurrentSends(t *testing.T) {
+	var running, maxRunning, calls atomic.Int32
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		n := running.Add(1)
+		defer running.Add(-1)
+		for m := maxRunning.Load(); n > m && !maxRunning.CompareAndSwap(m, n); m = maxRunning.Load() {
+		}
+		time.Sleep(2 * time.Millisecond)
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	p := load(t)
+	p.MaxConcurrentSends = 3
+	m, err := p.Message("a1", "r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if err := m.Send("title", "message"); err != nil {
+					t.Errorf("cannot send message: %s", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := m.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 50 || maxRunning.Load() > 3 {
+		t.Errorf("sent %d messages with %d in flight, want 50 with at most 3", calls.Load(), maxRunning.Load())
+	}
+
+	release := make(chan struct{})
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	p = load(t)
+	p.MaxConcurrentSends, p.DropWhenBusy = 1, true
+	m, _ = p.Message("a1", "r1")
+	if err := m.Send("title", "first"); err != nil {
+		t.Fatalf("cannot send message: %s", err)
+	}
+	throttled := p.MustMessage("a1", "r2")
+	throttled.Throttle(time.Hour)
+	if err := throttled.Send("title", "second"); err != ErrTooManySends {
+		t.Errorf("send exceeding the limit returned %v, want ErrTooManySends", err)
+	}
+	if allowed, dropped := throttled.ThrottleStats(); allowed != 0 || dropped != 0 || throttled.WouldThrottle() {
+		t.Errorf("dropped send consumed the throttle, allowed=%d, dropped=%d", allowed, dropped)
+	}
+
+	// logging is not blocked by a busy limit even without DropWhenBusy
+	p.DropWhenBusy = false
+	logger := slog.New(NewSlogHandler(&m, slog.LevelError))
+	logged := make(chan struct{})
+	go func() {
+		logger.Error("disk full")
+		close(logged)
+	}()
+	select {
+	case <-logged:
+	case <-time.After(time.Second):
+		t.Errorf("logging blocked while the limit of sends is in flight")
 	}
 
+	close(release)
+	m.Flush(context.Background())
+	if err := throttled.Send("title", "third"); err != nil {
+		t.Errorf("cannot send after the slot was released: %s", err)
+	}
+	throttled.Flush(context.Background())
+}
+
+func TestSendCtx(t *testing.T) {
+	release := make(chan struct{})
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	defer close(release)
+	m := message(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	for i := 0; i < 3; i++ {
+		if err := m.SendCtx(ctx, "title", "message"); err != nil {
+			t.Fatalf("cannot send message: %s", err)
+		}
+	}
+	if m.Pending() != 3 {
+		t.Errorf("pending=%d, want 3", m.Pending())
+	}
+	cancel()
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), time.Second)
+	defer flushCancel()
+	if err := m.Flush(flushCtx); err != nil || m.Pending() != 0 {
+		t.Errorf("flush after cancel: pending=%d, err=%v", m.Pending(), err)
+	}
+}
+
+func TestResponseBodyLimit(t *testing.T) {
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":1,"request":"`)
+		w.Write(make([]byte, 2*maxBody))
+	})
+	m := message(t)
+	if err := m.SendAndWait("title", "message", time.Second); !errors.Is(err, errBodyTooLarge) {
+		t.Errorf("oversized response returned %v, want errBodyTooLarge", err)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	var got url.Values
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		got = r.PostForm
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	if err := Notify("sample.json", "a2", "r1", "Backup", "done"); err != nil {
+		t.Fatalf("cannot notify: %s", err)
+	}
+	if got.Get("token") != "app2" || got.Get("user") != "rec1" || got.Get("title") != "Backup" || got.Get("message") != "done" {
+		t.Errorf("notify posted %v", got)
+	}
+	if err := Notify("sample.json", "a3", "r1", "Backup", "done"); err == nil {
+		t.Errorf("notify with unknown application returned no error")
+	}
+	if err := Notify("missing.json", "a1", "r1", "Backup", "done"); !errors.Is(err, ErrConfigNotFound) {
+		t.Errorf("notify with missing config returned %v, want ErrConfigNotFound", err)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	status := http.StatusTooManyRequests
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"status":0,"errors":["message limit reached"],"request":"req"}`)
+	})
+	p := load(t)
+	var fallbacks []string
+	p.Fallback = func(title, message string) error {
+		fallbacks = append(fallbacks, title+"/"+message)
+		return nil
+	}
+	m := p.MustMessage("a1", "r1")
+	var apiErr *APIError
+	if err := m.SendAndWait("disk", "full", time.Second); !errors.As(err, &apiErr) {
+		t.Errorf("send over quota returned %v, want APIError", err)
+	}
+	if len(fallbacks) != 1 || fallbacks[0] != "disk/full" {
+		t.Errorf("fallback called with %v, want disk/full", fallbacks)
+	}
+
+	status = http.StatusInternalServerError
+	m.SendAndWait("disk", "full", time.Second)
+	m.Throttle(time.Hour)
+	m.SendAndWait("disk", "full", time.Second)
+	if err := m.SendAndWait("disk", "full", time.Second); err != ErrThrottled {
+		t.Fatalf("throttled send returned %v", err)
+	}
+	if len(fallbacks) != 1 {
+		t.Errorf("fallback called for transient or throttled sends: %v", fallbacks)
+	}
+
+	status = http.StatusBadRequest
+	m.ResetThrottle()
+	failing := errors.New("smtp down")
+	p.Fallback = func(title, message string) error { return failing }
+	if err := m.SendAndWait("disk", "full", time.Second); !errors.Is(err, failing) || !errors.As(err, &apiErr) {
+		t.Errorf("failing fallback returned %v, want both errors", err)
+	}
+}
+
+func TestSendText(t *testing.T) {
+	posted := make(chan url.Values, 1)
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		posted <- r.PostForm
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	m := message(t)
+	if err := m.SendText("Build", `<b>not bold</b> & "x" > y`); err != nil {
+		t.Fatalf("cannot send text: %s", err)
+	}
+	v := <-posted
+	if v.Get("html") != "1" || v.Get("message") != "&lt;b&gt;not bold&lt;/b&gt; &amp; &#34;x&#34; &gt; y" {
+		t.Errorf("text posted as html=%s message=%q", v.Get("html"), v.Get("message"))
+	}
+}
+
+func TestSendHTMLTemplate(t *testing.T) {
+	posted := make(chan url.Values, 1)
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		posted <- r.PostForm
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	m := message(t)
+	tmpl := htmltemplate.Must(htmltemplate.New("alert").Parse(`<b>{{.Host}}</b> is down`))
+	if err := m.SendHTMLTemplate(tmpl, map[string]string{"Host": "<script>alert(1)</script>"}); err != nil {
+		t.Fatalf("cannot send template: %s", err)
+	}
+	v := <-posted
+	if v.Get("html") != "1" || v.Get("message") != "<b>&lt;script&gt;alert(1)&lt;/script&gt;</b> is down" {
+		t.Errorf("template posted as html=%s message=%q", v.Get("html"), v.Get("message"))
+	}
+
+	broken := htmltemplate.Must(htmltemplate.New("broken").Parse(`{{.Missing}}`))
+	if err := m.SendHTMLTemplate(broken, struct{}{}); err == nil {
+		t.Errorf("failing template sent without error")
+	}
+}
+
+func TestWouldExceedQuota(t *testing.T) {
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Limit-App-Limit", "10000")
+		w.Header().Set("X-Limit-App-Remaining", "5")
+		w.Header().Set("X-Limit-App-Reset", fmt.Sprint(time.Now().Add(time.Hour).Unix()))
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	m := message(t)
+	if m.WouldExceedQuota(100) {
+		t.Errorf("quota exceeded before any limit headers were observed")
+	}
+	if err := m.SendAndWait("title", "message", time.Second); err != nil {
+		t.Fatalf("cannot send message: %s", err)
+	}
+	if m.WouldExceedQuota(5) {
+		t.Errorf("5 messages exceed remaining quota of 5")
+	}
+	if !m.WouldExceedQuota(6) {
+		t.Errorf("6 messages do not exceed remaining quota of 5")
+	}
+}
+
+func TestConserveBelow(t *testing.T) {
+	posted := make(chan url.Values, 1)
+	remaining := 100
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		posted <- r.PostForm
+		remaining--
+		w.Header().Set("X-Limit-App-Limit", "10000")
+		w.Header().Set("X-Limit-App-Remaining", fmt.Sprint(remaining))
+		w.Header().Set("X-Limit-App-Reset", fmt.Sprint(time.Now().Add(time.Hour).Unix()))
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	p := load(t)
+	p.ConserveBelow = 98
+	m := p.MustMessage("a1", "r1", WithPriority(PriorityHigh))
+	emergency := p.MustMessage("a1", "r2", WithPriority(PriorityEmergency))
+	for i, tc := range []struct {
+		m    *Message
+		want string
+	}{
+		{&m, "1"},         // no limit known yet
+		{&m, "1"},         // 99 remaining
+		{&m, "1"},         // 98 remaining, not below the threshold
+		{&emergency, "2"}, // 97 remaining, emergency is kept
+		{&m, "-2"},        // 96 remaining
+	} {
+		if err := tc.m.SendAndWait("title", "message", time.Second); err != nil {
+			t.Fatal(err)
+		}
+		if v := <-posted; v.Get("priority") != tc.want {
+			t.Errorf("send #%d posted priority %s, want %s", i, v.Get("priority"), tc.want)
+		}
+	}
+}
+
+// Redirect api calls to a mock server for the duration of the test
+func mock(t *testing.T, h http.HandlerFunc) *httptest.Server {
+	srv := httptest.NewServer(h)
+	old := apiURL
+	apiURL = srv.URL
+	t.Cleanup(func() {
+		apiURL = old
+		srv.Close()
+	})
+	return srv
 }
 
 func message(t *testing.T) Message {
@@ -88,3 +1262,30 @@ func load(t *testing.T) Pushover {
 	}
 	return p
 }
+
+func BenchmarkValues(b *testing.B) {
+	m := benchMessage(b)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.values("title", "message")
+	}
+}
+
+func BenchmarkValuesUncached(b *testing.B) {
+	m := benchMessage(b)
+	m.form = nil
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.values("title", "message")
+	}
+}
+
+func benchMessage(b *testing.B) Message {
+	p, err := Load("sample.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	m := p.MustMessage("a1", "work")
+	m.Set(WithPriority(PriorityHigh), WithSound("siren"), WithURL("https://example.com", "Dashboard"), WithTTL(time.Hour))
+	return m
+}
@@ -0,0 +1,98 @@
+package pushover
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Daily time window from start to end in a time zone, a window with end before start
+// crosses midnight. Times are "15:04" formatted, the time zone is an IANA name like
+// "Europe/Berlin" or empty for local time.
+type DailyWindow struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	TimeZone string `json:"timezone,omitempty"`
+}
+
+// Check if t is within the window
+func (w DailyWindow) contains(t time.Time) (bool, error) {
+	start, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return false, fmt.Errorf("invalid window start %q", w.Start)
+	}
+	end, err := time.Parse("15:04", w.End)
+	if err != nil {
+		return false, fmt.Errorf("invalid window end %q", w.End)
+	}
+	loc := time.Local
+	if w.TimeZone != "" {
+		if loc, err = time.LoadLocation(w.TimeZone); err != nil {
+			return false, err
+		}
+	}
+	t = t.In(loc)
+	now := t.Hour()*60 + t.Minute()
+	s, e := start.Hour()*60+start.Minute(), end.Hour()*60+end.Minute()
+	if s <= e {
+		return s <= now && now < e, nil
+	}
+	return now >= s || now < e, nil
+}
+
+// Handling of messages during quiet hours
+type QuietPolicy string
+
+const (
+	QuietSilent   QuietPolicy = "silent"   // send with PriorityLow, without sound or vibration
+	QuietSuppress QuietPolicy = "suppress" // do not send, returns ErrQuietHours
+)
+
+// Error returned for messages suppressed during quiet hours
+var ErrQuietHours = errors.New("pushover message suppressed during quiet hours")
+
+// Quiet hours for all messages of a Pushover. Messages sent within the window are sent
+// silently or suppressed depending on the policy, silent if empty. Emergency messages
+// always go through. In the config file:
+//
+//	"quiet_hours": {"start": "22:00", "end": "07:00", "timezone": "Europe/Berlin", "policy": "suppress"}
+type QuietHours struct {
+	DailyWindow
+	Policy QuietPolicy `json:"policy,omitempty"`
+}
+
+// Check the quiet hours settings, used when loading a config
+func (q *QuietHours) check() error {
+	if q == nil {
+		return nil
+	}
+	if q.Policy != "" && q.Policy != QuietSilent && q.Policy != QuietSuppress {
+		return fmt.Errorf("invalid quiet hours policy %q", q.Policy)
+	}
+	_, err := q.contains(time.Time{})
+	return err
+}
+
+// Apply quiet hours to message values sent at now
+func (q *QuietHours) apply(v url.Values, now time.Time) error {
+	if q == nil {
+		return nil
+	}
+	priority, _ := strconv.Atoi(v.Get("priority"))
+	if Priority(priority) == PriorityEmergency {
+		return nil
+	}
+	quiet, err := q.contains(now)
+	if err != nil || !quiet {
+		return err
+	}
+	if q.Policy == QuietSuppress {
+		return ErrQuietHours
+	}
+	if Priority(priority) > PriorityLow {
+		v.Set("priority", strconv.Itoa(int(PriorityLow)))
+	}
+	return nil
+}
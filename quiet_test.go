@@ -0,0 +1,74 @@
+package pushover
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuietHours(t *testing.T) {
+	q := &QuietHours{DailyWindow: DailyWindow{Start: "22:00", End: "07:00", TimeZone: "Europe/Berlin"}}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no time zone data: %s", err)
+	}
+	at := func(hour, min int) time.Time {
+		// sent from another time zone, compared in Berlin time
+		return time.Date(2024, 1, 15, hour, min, 0, 0, berlin).UTC()
+	}
+	for _, tc := range []struct {
+		now   time.Time
+		quiet bool
+	}{
+		{at(21, 59), false},
+		{at(22, 0), true},
+		{at(23, 59), true},
+		{at(0, 0), true},
+		{at(6, 59), true},
+		{at(7, 0), false},
+		{at(12, 0), false},
+	} {
+		v := url.Values{"priority": {"1"}}
+		if err := q.apply(v, tc.now); err != nil {
+			t.Fatal(err)
+		}
+		if quiet := v.Get("priority") == "-1"; quiet != tc.quiet {
+			t.Errorf("at %s quiet=%t, want %t", tc.now.In(berlin).Format("15:04"), quiet, tc.quiet)
+		}
+	}
+
+	// lower priorities are kept, emergency always goes through
+	v := url.Values{"priority": {"-2"}}
+	q.apply(v, at(23, 0))
+	if v.Get("priority") != "-2" {
+		t.Errorf("lowest priority raised to %s during quiet hours", v.Get("priority"))
+	}
+	q.Policy = QuietSuppress
+	if err := q.apply(url.Values{}, at(23, 0)); !errors.Is(err, ErrQuietHours) {
+		t.Errorf("suppressed message returned %v, want ErrQuietHours", err)
+	}
+	if err := q.apply(url.Values{"priority": {"2"}}, at(23, 0)); err != nil {
+		t.Errorf("emergency message suppressed during quiet hours: %s", err)
+	}
+	if err := q.apply(url.Values{}, at(8, 0)); err != nil {
+		t.Errorf("message suppressed outside quiet hours: %s", err)
+	}
+}
+
+func TestQuietHoursConfig(t *testing.T) {
+	p, err := LoadReader(strings.NewReader(`{"quiet_hours": {"start": "22:00", "end": "07:00", "policy": "suppress"}}`))
+	if err != nil || p.QuietHours == nil || p.QuietHours.Policy != QuietSuppress || p.QuietHours.End != "07:00" {
+		t.Errorf("loaded quiet hours %+v, err=%v", p.QuietHours, err)
+	}
+	for _, config := range []string{
+		`{"quiet_hours": {"start": "22", "end": "07:00"}}`,
+		`{"quiet_hours": {"start": "22:00", "end": "07:00", "timezone": "Mars/Olympus"}}`,
+		`{"quiet_hours": {"start": "22:00", "end": "07:00", "policy": "mute"}}`,
+	} {
+		if _, err := LoadReader(strings.NewReader(config)); !errors.Is(err, ErrConfigInvalid) {
+			t.Errorf("loaded %s, err=%v", config, err)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package pushover
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Result of a message delivered to the api, see SendResult()
+type Result struct {
+	Request string `json:"request"` // request id of the api
+	Receipt string `json:"receipt"` // receipt of emergency messages, see Receipt()
+
+	// Time of the api server from the Date header of the response, zero if missing, and how
+	// far the local clock is ahead of it. The header has a resolution of one second, so
+	// skews of a few seconds are normal. Larger skews explain odd message timestamps.
+	ServerTime time.Time     `json:"-"`
+	ClockSkew  time.Duration `json:"-"`
+}
+
+// Take the server time from a response received at local time now
+func (r *Result) observe(h http.Header, now time.Time) {
+	t, err := http.ParseTime(h.Get("Date"))
+	if err != nil {
+		return
+	}
+	r.ServerTime = t
+	r.ClockSkew = now.Sub(t).Truncate(time.Second)
+}
+
+// Send a message with timeout like SendAndWait(), returning the result of the api
+func (m *Message) SendResult(title, message string, timeout time.Duration) (Result, error) {
+	ctx, cancel := timeoutContext(context.Background(), timeout)
+	defer cancel()
+	var r Result
+	err := m.sendWait(ctx, m.values(title, message), nil, &r)
+	return r, err
+}
@@ -0,0 +1,35 @@
+package pushover
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSendResult(t *testing.T) {
+	server := time.Now().Add(-time.Hour).UTC().Truncate(time.Second) // local clock an hour ahead
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", server.Format(http.TimeFormat))
+		fmt.Fprint(w, `{"status":1,"request":"req-1","receipt":"rcpt"}`)
+	})
+	m := message(t)
+	r, err := m.SendResult("title", "message", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Request != "req-1" || r.Receipt != "rcpt" || !r.ServerTime.Equal(server) {
+		t.Errorf("result %+v, want request req-1, receipt rcpt and server time %s", r, server)
+	}
+	if r.ClockSkew < time.Hour-2*time.Second || r.ClockSkew > time.Hour+2*time.Second {
+		t.Errorf("clock skew %s, want about an hour", r.ClockSkew)
+	}
+
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Date"] = nil // suppress the header set by the server
+		fmt.Fprint(w, `{"status":1,"request":"req-2"}`)
+	})
+	if r, err := m.SendResult("title", "message", time.Second); err != nil || !r.ServerTime.IsZero() || r.ClockSkew != 0 {
+		t.Errorf("result without date header %+v, err=%v", r, err)
+	}
+}
@@ -0,0 +1,88 @@
+package pushover
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+)
+
+// Handler sending log records as messages, see NewSlogHandler()
+type slogHandler struct {
+	m      *Message
+	min    slog.Level
+	attrs  string // formatted attributes added with WithAttrs()
+	prefix string // group prefix of attribute keys, ending with a dot
+}
+
+// Create a slog.Handler sending records at or above minLevel as messages, records below
+// are dropped. The level is the title, the message is followed by the attributes as
+// key=value lines. Records are sent in background with the priority of their level, see
+// SendLevel(), so logging does not block. Throttled and duplicate records are dropped
+// silently, as are records exceeding Pushover.MaxConcurrentSends.
+//
+//	logger := slog.New(pushover.NewSlogHandler(&m, slog.LevelError))
+func NewSlogHandler(m *Message, minLevel slog.Level) slog.Handler {
+	return &slogHandler{m: m, min: minLevel}
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool { return level >= h.min }
+
+func (h *slogHandler) Handle(_ context.Context, r slog.Record) error {
+	if r.Level < h.min {
+		return nil
+	}
+	var b strings.Builder
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		writeAttr(&b, h.prefix, a)
+		return true
+	})
+	err := h.m.sendBackgroundSlot(context.Background(), h.m.levelValues(r.Level, r.Level.String(), b.String()), nil, true)
+	if errors.Is(err, ErrThrottled) || errors.Is(err, ErrDuplicate) || errors.Is(err, ErrTooManySends) {
+		return nil
+	}
+	return err
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	for _, a := range attrs {
+		writeAttr(&b, h.prefix, a)
+	}
+	c := *h
+	c.attrs += b.String()
+	return &c
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	c.prefix += name + "."
+	return &c
+}
+
+// Write an attribute as key=value line, groups are flattened to dotted keys
+func writeAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			writeAttr(b, prefix, ga)
+		}
+		return
+	}
+	b.WriteString("\n")
+	b.WriteString(prefix)
+	b.WriteString(a.Key)
+	b.WriteString("=")
+	b.WriteString(a.Value.String())
+}
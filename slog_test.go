@@ -0,0 +1,52 @@
+package pushover
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSlogHandler(t *testing.T) {
+	posted := make(chan url.Values, 10)
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		posted <- r.PostForm
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	m := message(t)
+	h := NewSlogHandler(&m, slog.LevelWarn)
+	if h.Enabled(context.Background(), slog.LevelInfo) || !h.Enabled(context.Background(), slog.LevelWarn) {
+		t.Errorf("handler enabled for wrong levels")
+	}
+	logger := slog.New(h).With("host", "web01").WithGroup("req")
+
+	logger.Info("ignored", "id", 1)
+	logger.Error("db down", "id", 7, slog.Group("db", "name", "orders"))
+	select {
+	case v := <-posted:
+		want := "db down\nhost=web01\nreq.id=7\nreq.db.name=orders"
+		if v.Get("title") != "ERROR" || v.Get("message") != want || v.Get("priority") != "1" {
+			t.Errorf("record posted title %q, priority %q, message %q", v.Get("title"), v.Get("priority"), v.Get("message"))
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("error record not sent")
+	}
+	m.Flush(context.Background())
+	if len(posted) > 0 {
+		t.Errorf("info record below minimum level sent")
+	}
+
+	m.Throttle(time.Hour)
+	logger.Warn("sent")
+	if err := h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelError, "throttled", 0)); err != nil {
+		t.Errorf("throttled record returned %s", err)
+	}
+	m.Flush(context.Background())
+	if len(posted) != 1 {
+		t.Errorf("sent %d records with throttle, want 1", len(posted))
+	}
+}
@@ -0,0 +1,113 @@
+package pushover
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Complete description of a message with every field the api supports, for sending it in
+// one declarative call with SendSpec(), e.g. decoded from JSON. Durations are in seconds
+// like in the api and in profiles. See https://pushover.net/api for the meaning of the
+// fields.
+type MessageSpec struct {
+	Title     string     `json:"title,omitempty"`
+	Message   string     `json:"message"`
+	Priority  Priority   `json:"priority,omitempty"`
+	Sound     string     `json:"sound,omitempty"`
+	URL       string     `json:"url,omitempty"`
+	URLTitle  string     `json:"url_title,omitempty"`
+	Device    []string   `json:"device,omitempty"`
+	TTL       int        `json:"ttl,omitempty"`       // seconds until the message is deleted
+	Timestamp *time.Time `json:"timestamp,omitempty"` // shown instead of the time received
+	HTML      bool       `json:"html,omitempty"`
+	Monospace bool       `json:"monospace,omitempty"`
+	Retry     int        `json:"retry,omitempty"`  // seconds, of emergency messages
+	Expire    int        `json:"expire,omitempty"` // seconds, of emergency messages
+	Tags      []string   `json:"tags,omitempty"`   // of emergency messages, to cancel by tag
+
+	Attachment     []byte `json:"attachment,omitempty"` // image, type detected from content
+	AttachmentName string `json:"attachment_name,omitempty"`
+}
+
+// Check the spec for fields the api would reject or ignore
+func (s MessageSpec) check() error {
+	switch {
+	case s.Message == "":
+		return errors.New("pushover message spec without message")
+	case s.HTML && s.Monospace:
+		return errors.New("pushover message spec cannot be html and monospace")
+	case s.Priority < PriorityLowest || s.Priority > PriorityEmergency:
+		return fmt.Errorf("pushover message spec with invalid priority %d", s.Priority)
+	case s.TTL < 0:
+		return errors.New("pushover message spec with negative ttl")
+	}
+	return checkEmergency(seconds(s.Retry), seconds(s.Expire))
+}
+
+func seconds(n int) time.Duration { return time.Duration(n) * time.Second }
+
+// Send the message described by the spec and wait for the result. Fields of the spec
+// override the settings of the message, zero fields keep them. The whole spec is
+// validated before anything is sent.
+func (m *Message) SendSpec(s MessageSpec) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	c := *m // shares the throttle state of m
+	if s.Priority != PriorityNormal {
+		c.Set(WithPriority(s.Priority))
+	}
+	if s.Sound != "" {
+		c.Set(WithSound(s.Sound))
+	}
+	if s.URL != "" {
+		c.Set(WithURL(s.URL, s.URLTitle))
+	}
+	if len(s.Device) > 0 {
+		c.Set(WithDevice(s.Device...))
+	}
+	if s.TTL > 0 {
+		c.Set(WithTTL(seconds(s.TTL)))
+	}
+	if s.Retry != 0 || s.Expire != 0 {
+		c.Set(WithRetry(seconds(s.Retry), seconds(s.Expire)))
+	}
+	if err := c.validate(); err != nil {
+		return err
+	}
+	if len(s.Tags) > 0 && c.priority != PriorityEmergency {
+		return errors.New("pushover message spec tags only apply to emergency messages")
+	}
+	var a *attachment
+	if len(s.Attachment) > 0 {
+		var err error
+		if a, err = readAttachment(bytes.NewReader(s.Attachment), s.AttachmentName, ""); err != nil {
+			return err
+		}
+		if err := c.checkDimensions(a); err != nil {
+			return err
+		}
+	}
+
+	v := c.values(s.Title, s.Message)
+	if s.Timestamp != nil {
+		v.Set("timestamp", strconv.FormatInt(s.Timestamp.Unix(), 10))
+	}
+	if s.HTML {
+		v.Set("html", "1")
+	}
+	if s.Monospace {
+		v.Set("monospace", "1")
+	}
+	if len(s.Tags) > 0 {
+		v.Set("tags", strings.Join(s.Tags, ","))
+	}
+	ctx, cancel := timeoutContext(context.Background(), defaultTimeout)
+	defer cancel()
+	return c.sendWait(ctx, v, a, nil)
+}
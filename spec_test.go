@@ -0,0 +1,89 @@
+package pushover
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSendSpec(t *testing.T) {
+	uploads := mockUpload(t)
+	m := message(t)
+	ts := time.Unix(1700000000, 0)
+	spec := MessageSpec{
+		Title:          "Disk",
+		Message:        "<b>full</b>",
+		Priority:       PriorityEmergency,
+		Sound:          "siren",
+		URL:            "https://example.com/disk",
+		URLTitle:       "Dashboard",
+		Device:         []string{"iphone", "ipad"},
+		TTL:            3600,
+		Timestamp:      &ts,
+		HTML:           true,
+		Retry:          60,
+		Expire:         1800,
+		Tags:           []string{"disk", "web01"},
+		Attachment:     pngData,
+		AttachmentName: "graph.png",
+	}
+	if err := m.SendSpec(spec); err != nil {
+		t.Fatal(err)
+	}
+	u := <-uploads
+	for k, want := range map[string]string{
+		"title": "Disk", "message": "<b>full</b>", "priority": "2", "sound": "siren",
+		"url": "https://example.com/disk", "url_title": "Dashboard", "device": "iphone,ipad",
+		"ttl": "3600", "timestamp": "1700000000", "html": "1", "retry": "60", "expire": "1800",
+		"tags": "disk,web01",
+	} {
+		if u.fields[k] != want {
+			t.Errorf("spec posted %s=%q, want %q", k, u.fields[k], want)
+		}
+	}
+	if u.filename != "graph.png" || u.mime != "image/png" || !bytes.Equal(u.data, pngData) {
+		t.Errorf("spec uploaded %s (%s) with %d bytes", u.filename, u.mime, len(u.data))
+	}
+	if v := m.values("t", "m"); v.Get("priority") != "" || v.Get("sound") != "" {
+		t.Errorf("spec changed the message settings: %v", v)
+	}
+
+	for _, bad := range []MessageSpec{
+		{Title: "no message"},
+		{Message: "m", HTML: true, Monospace: true},
+		{Message: "m", Tags: []string{"x"}},
+		{Message: "m", Priority: 3},
+		{Message: "m", Priority: -3},
+		{Message: "m", TTL: -1},
+		{Message: "m", Priority: PriorityEmergency, Retry: 1},
+		{Message: "m", URL: "ftp://example.com"},
+		{Message: "m", Attachment: []byte("not an image")},
+	} {
+		if err := m.SendSpec(bad); err == nil {
+			t.Errorf("invalid spec %+v sent", bad)
+		}
+	}
+
+	// tags need the effective priority to be emergency, set by the spec or the message
+	emergency := message(t)
+	emergency.Set(WithPriority(PriorityEmergency))
+	if err := emergency.SendSpec(MessageSpec{Message: "m", Tags: []string{"disk"}, Attachment: pngData}); err != nil {
+		t.Errorf("tags rejected for emergency message: %s", err)
+	}
+	<-uploads
+}
+
+func TestMessageSpecJSON(t *testing.T) {
+	var s MessageSpec
+	if err := json.Unmarshal([]byte(`{"message":"m","ttl":3600,"retry":60,"expire":1800}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.TTL != 3600 || s.Retry != 60 || s.Expire != 1800 || s.Timestamp != nil {
+		t.Errorf("decoded spec %+v", s)
+	}
+	b, err := json.Marshal(MessageSpec{Message: "m"})
+	if err != nil || string(b) != `{"message":"m"}` {
+		t.Errorf("encoded zero spec as %s, err=%v", b, err)
+	}
+}
@@ -0,0 +1,111 @@
+package pushover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// Maximum message length in runes accepted by the api
+const maxMessage = 1024
+
+// Error returned for messages longer than 1024 runes with RejectLong
+var ErrTooLong = errors.New("pushover message longer than 1024 characters")
+
+// Handling of messages longer than the api limit of 1024 runes, see WithTruncate()
+type TruncateMode int
+
+const (
+	SendLong     TruncateMode = iota // send unchanged, the api rejects the message
+	RejectLong                       // return ErrTooLong without sending
+	TruncateLong                     // cut the message, ending with an ellipsis
+	SplitLong                        // send a sequence of messages with (1/3) title counters
+)
+
+// Handle messages exceeding 1024 runes gracefully, e.g. long log dumps. With SplitLong the
+// parts are sent one after the other and pass the throttle as a single message, the first
+// failing part stops the sequence. Lengths apply to the text as sent, so HTML markup of
+// SendMarkdown() and SendText() counts and may be cut.
+func WithTruncate(mode TruncateMode) Option {
+	return func(m *Message) { m.truncate = mode }
+}
+
+// Check the message length before sending
+func (m *Message) checkLength(v url.Values) error {
+	if m.truncate == RejectLong && utf8.RuneCountInString(v.Get("message")) > maxMessage {
+		return ErrTooLong
+	}
+	return nil
+}
+
+// Fit message values into the api limit according to the truncate mode. The values are
+// not modified, so a Fallback still gets the complete message.
+func (m *Message) fit(v url.Values) []url.Values {
+	title, message := v.Get("title"), v.Get("message")
+	if utf8.RuneCountInString(message) <= maxMessage {
+		return []url.Values{v}
+	}
+	switch m.truncate {
+	case TruncateLong:
+		return []url.Values{with(v, title, string([]rune(message)[:maxMessage-1])+"…")}
+	case SplitLong:
+		parts := split(message, maxMessage)
+		vs := make([]url.Values, len(parts))
+		for i, part := range parts {
+			vs[i] = with(v, strings.TrimSpace(fmt.Sprintf("%s (%d/%d)", title, i+1, len(parts))), part)
+		}
+		return vs
+	}
+	return []url.Values{v}
+}
+
+// Send parts of a long report as a sequence of messages titled "title (1/3)", "title (2/3)"
+// and so on. The parts are sent one after the other, waiting for each, so they arrive in
+// order. Every part passes the throttle on its own, with Throttle() set later parts are
+// throttled. Returns the error of every part at the part's index.
+func (m *Message) SendSeries(title string, parts []string) Errors {
+	errs := make(Errors, len(parts))
+	for i, part := range parts {
+		v := m.values(strings.TrimSpace(fmt.Sprintf("%s (%d/%d)", title, i+1, len(parts))), part)
+		ctx, cancel := timeoutContext(context.Background(), defaultTimeout)
+		errs[i] = m.sendWait(ctx, v, nil, nil)
+		cancel()
+	}
+	return errs
+}
+
+// Copy of message values with a different title and message, values without title
+// stay without, see NoTitle()
+func with(v url.Values, title, message string) url.Values {
+	c := make(url.Values, len(v))
+	for k, s := range v {
+		c[k] = s
+	}
+	if v.Has("title") {
+		c.Set("title", title)
+	}
+	c.Set("message", message)
+	return c
+}
+
+// Split s into parts of at most n runes, preferring to break after a newline in the
+// second half of a part
+func split(s string, n int) []string {
+	var parts []string
+	r := []rune(s)
+	for len(r) > n {
+		cut := n
+		for i := n - 1; i >= n/2; i-- {
+			if r[i] == '\n' {
+				cut = i + 1
+				break
+			}
+		}
+		parts = append(parts, string(r[:cut]))
+		r = r[cut:]
+	}
+	return append(parts, string(r))
+}
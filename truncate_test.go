@@ -0,0 +1,107 @@
+package pushover
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestTruncateMode(t *testing.T) {
+	var posted []url.Values
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		posted = append(posted, r.PostForm)
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	long := strings.Repeat("ä", 2500)
+
+	for _, tc := range []struct {
+		mode   TruncateMode
+		err    error
+		titles []string
+	}{
+		{SendLong, nil, []string{"log"}},
+		{RejectLong, ErrTooLong, nil},
+		{TruncateLong, nil, []string{"log"}},
+		{SplitLong, nil, []string{"log (1/3)", "log (2/3)", "log (3/3)"}},
+	} {
+		posted = nil
+		m := message(t)
+		m.Set(WithTruncate(tc.mode))
+		m.Throttle(time.Hour) // a split message passes the throttle once
+		if err := m.SendAndWait("log", long, time.Second); !errors.Is(err, tc.err) {
+			t.Errorf("mode %d returned %v, want %v", tc.mode, err, tc.err)
+		}
+		if len(posted) != len(tc.titles) {
+			t.Fatalf("mode %d posted %d messages, want %d", tc.mode, len(posted), len(tc.titles))
+		}
+		var text string
+		for i, v := range posted {
+			if v.Get("title") != tc.titles[i] {
+				t.Errorf("mode %d posted title %q, want %q", tc.mode, v.Get("title"), tc.titles[i])
+			}
+			if n := utf8.RuneCountInString(v.Get("message")); tc.mode != SendLong && n > maxMessage {
+				t.Errorf("mode %d posted %d characters", tc.mode, n)
+			}
+			text += v.Get("message")
+		}
+		switch tc.mode {
+		case TruncateLong:
+			if !strings.HasSuffix(text, "ä…") {
+				t.Errorf("truncated message does not end with an ellipsis")
+			}
+		case SplitLong, SendLong:
+			if text != long {
+				t.Errorf("mode %d posted %d characters, want the complete message", tc.mode, utf8.RuneCountInString(text))
+			}
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	s := strings.Repeat("x", 7) + "\n" + strings.Repeat("y", 5)
+	if got := split(s, 10); len(got) != 2 || got[0] != strings.Repeat("x", 7)+"\n" {
+		t.Errorf("split at newline returned %q", got)
+	}
+	if got := split(strings.Repeat("z", 25), 10); len(got) != 3 || got[2] != "zzzzz" {
+		t.Errorf("split without newline returned %q", got)
+	}
+}
+
+func TestSendSeries(t *testing.T) {
+	var posted []url.Values
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		posted = append(posted, r.PostForm)
+		if r.PostForm.Get("message") == "bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":0,"errors":["message is invalid"]}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	m := message(t)
+	errs := m.SendSeries("Report", []string{"one", "bad", "three"})
+	if len(errs) != 3 || errs[0] != nil || errs[1] == nil || errs[2] != nil {
+		t.Errorf("series returned %v, want error for second part only", errs)
+	}
+	if len(posted) != 3 {
+		t.Fatalf("series posted %d messages, want 3", len(posted))
+	}
+	for i, want := range []string{"one", "bad", "three"} {
+		if posted[i].Get("message") != want || posted[i].Get("title") != fmt.Sprintf("Report (%d/3)", i+1) {
+			t.Errorf("part %d posted %q with title %q", i, posted[i].Get("message"), posted[i].Get("title"))
+		}
+	}
+
+	posted = nil
+	m.Throttle(time.Hour)
+	if errs := m.SendSeries("Report", []string{"one", "two"}); errs[0] != nil || !errors.Is(errs[1], ErrThrottled) {
+		t.Errorf("throttled series returned %v", errs)
+	}
+}
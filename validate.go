@@ -0,0 +1,172 @@
+package pushover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// Number of api calls run concurrently by checks of the whole config
+const checkConcurrency = 4
+
+// Retrieve the sounds available for an application, mapping sound name to description.
+// Also a cheap way to check if an application token is valid.
+func (p *Pushover) Sounds(ctx context.Context, app string) (map[string]string, error) {
+	token, ok := p.App[app]
+	if !ok {
+		return nil, fmt.Errorf("invalid pushover application: %s", app)
+	}
+	var r struct {
+		Sounds map[string]string `json:"sounds"`
+	}
+	_, err := p.call(ctx, http.MethodGet, "/sounds.json?"+url.Values{"token": {token}}.Encode(), "", nil, &r)
+	return r.Sounds, err
+}
+
+// Check all configured application tokens with the api, without sending a message.
+// Returns the result for every application name, nil if the token is valid. Used e.g.
+// to verify all keys at startup of a CLI with a --check flag.
+func (p *Pushover) SelfTest(ctx context.Context) ErrorMap {
+	names := make([]string, 0, len(p.App))
+	for name := range p.App {
+		names = append(names, name)
+	}
+	return checkAll(names, func(name string) error {
+		_, err := p.Sounds(ctx, name)
+		return err
+	})
+}
+
+// Title of test notifications sent by SendTest()
+const testTitle = "🔔 pushover test"
+
+// Send a clearly marked test notification and wait for the result, to confirm delivery
+// end to end e.g. from a CLI --test flag. Throttle, dedup and quiet hours are bypassed so
+// the test always fires, the throttle timer is not advanced.
+func (m *Message) SendTest() error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	host, _ := os.Hostname()
+	ctx, cancel := timeoutContext(context.Background(), defaultTimeout)
+	defer cancel()
+	return m.pushover(ctx, m.values(testTitle, "Test notification from "+host), nil, nil)
+}
+
+// Errors returned by Verify(), the api error is wrapped, too
+var (
+	ErrInvalidToken    = errors.New("pushover application token rejected")
+	ErrInvalidReceiver = errors.New("pushover receiver key rejected")
+	ErrNoActiveDevices = errors.New("pushover receiver has no active devices")
+)
+
+// Check at startup that an application token works and a receiver is valid with active
+// devices, without sending a visible message. For a receiver restricted to devices in the
+// config, one of these devices must be active. Network errors are returned unchanged.
+func (p *Pushover) Verify(ctx context.Context, app, rec string) error {
+	m, err := p.Message(app, rec)
+	if err != nil {
+		return err
+	}
+	if _, err := p.Sounds(ctx, app); err != nil {
+		if permanent(err) {
+			return fmt.Errorf("%w: %w", ErrInvalidToken, err)
+		}
+		return err
+	}
+	return p.validateReceiver(ctx, m, rec)
+}
+
+// Check all configured receivers with the api, using the first application in alphabetical
+// order. Returns the result for every receiver name, nil if it is valid and has active
+// devices, like Verify(). Used e.g. by a CLI --validate flag.
+func (p *Pushover) ValidateAll(ctx context.Context) ErrorMap {
+	apps := sortedNames(p.App)
+	return checkAll(sortedNames(p.Rec), func(rec string) error {
+		if len(apps) == 0 {
+			return errors.New("no pushover application configured")
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		m, err := p.Message(apps[0], rec)
+		if err != nil {
+			return err
+		}
+		return p.validateReceiver(ctx, m, rec)
+	})
+}
+
+// Check the receiver of message m with the validation endpoint
+func (p *Pushover) validateReceiver(ctx context.Context, m Message, rec string) error {
+	group, devices, err := p.validateKey(ctx, m)
+	if err != nil || group {
+		return err // groups report no devices
+	}
+	for _, d := range devices {
+		if len(m.device) == 0 || contains(m.device, d) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrNoActiveDevices, rec)
+}
+
+// Names of the active devices of a receiver as reported by the api, e.g. to let users pick
+// a target device for WithDevice(). Groups report no devices.
+func (p *Pushover) ActiveDevices(ctx context.Context, app, rec string) ([]string, error) {
+	m, err := p.Message(app, rec)
+	if err != nil {
+		return nil, err
+	}
+	_, devices, err := p.validateKey(ctx, m)
+	return devices, err
+}
+
+// Call the validation endpoint for the receiver of message m
+func (p *Pushover) validateKey(ctx context.Context, m Message) (group bool, devices []string, err error) {
+	var r struct {
+		Group   int      `json:"group"`
+		Devices []string `json:"devices"`
+	}
+	if _, err := p.postForm(ctx, "/users/validate.json", url.Values{"token": {m.app}, "user": {m.rec}}, &r); err != nil {
+		if permanent(err) {
+			return false, nil, fmt.Errorf("%w: %w", ErrInvalidReceiver, err)
+		}
+		return false, nil, err
+	}
+	return r.Group == 1, r.Devices, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Run check for all names with bounded concurrency
+func checkAll(names []string, check func(string) error) ErrorMap {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(ErrorMap, len(names))
+	sem := make(chan struct{}, checkConcurrency)
+	for _, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(name string) {
+			defer func() { <-sem; wg.Done() }()
+			err := check(name)
+			mu.Lock()
+			results[name] = err
+			mu.Unlock()
+		}(name)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,182 @@
+package pushover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSelfTest(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for m := maxRunning.Load(); n > m && !maxRunning.CompareAndSwap(m, n); m = maxRunning.Load() {
+		}
+		time.Sleep(5 * time.Millisecond)
+		if r.URL.Path != "/sounds.json" {
+			t.Errorf("unexpected request for %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("token") == "bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"token":"invalid","errors":["application token is invalid"],"status":0}`)
+			return
+		}
+		fmt.Fprint(w, `{"sounds":{"pushover":"Pushover (default)","siren":"Siren"},"status":1}`)
+	})
+	p := load(t)
+	for i := 0; i < 10; i++ {
+		p.App[fmt.Sprint("ok", i)] = "good"
+	}
+	p.App["broken"] = "bad"
+
+	results := p.SelfTest(context.Background())
+	if len(results) != len(p.App) {
+		t.Errorf("self test checked %d apps, want %d", len(results), len(p.App))
+	}
+	for name, err := range results {
+		if (name == "broken") != (err != nil) {
+			t.Errorf("self test of %s returned %v", name, err)
+		}
+	}
+	if maxRunning.Load() > checkConcurrency {
+		t.Errorf("self test ran %d checks concurrently, want at most %d", maxRunning.Load(), checkConcurrency)
+	}
+
+	sounds, err := p.Sounds(context.Background(), "a1")
+	if err != nil || sounds["siren"] != "Siren" {
+		t.Errorf("sounds=%v, err=%v", sounds, err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch {
+		case r.Form.Get("token") == "bad":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"token":"invalid","errors":["application token is invalid"],"status":0}`)
+		case r.URL.Path == "/sounds.json":
+			fmt.Fprint(w, `{"sounds":{},"status":1}`)
+		case r.Form.Get("user") == "bad":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"user":"invalid","errors":["user key is invalid"],"status":0}`)
+		case r.Form.Get("user") == "idle":
+			fmt.Fprint(w, `{"status":1,"group":0,"devices":[]}`)
+		case r.Form.Get("user") == "group":
+			fmt.Fprint(w, `{"status":1,"group":1,"devices":[]}`)
+		default:
+			fmt.Fprint(w, `{"status":1,"group":0,"devices":["iphone"]}`)
+		}
+	})
+	p := load(t)
+	p.App["broken"] = "bad"
+	p.Rec["broken"] = Receiver{Key: "bad"}
+	p.Rec["idle"] = Receiver{Key: "idle"}
+	p.Rec["group"] = Receiver{Key: "group"}
+	for _, tc := range []struct {
+		app, rec string
+		want     error
+	}{
+		{"a1", "r1", nil},
+		{"a1", "group", nil},
+		{"broken", "r1", ErrInvalidToken},
+		{"a1", "broken", ErrInvalidReceiver},
+		{"a1", "idle", ErrNoActiveDevices},
+		{"a1", "work", ErrNoActiveDevices}, // restricted to work-phone
+	} {
+		if err := p.Verify(context.Background(), tc.app, tc.rec); !errors.Is(err, tc.want) {
+			t.Errorf("verify %s/%s returned %v, want %v", tc.app, tc.rec, err, tc.want)
+		}
+	}
+	if err := p.Verify(context.Background(), "a1", "missing"); err == nil {
+		t.Errorf("verified receiver missing in config")
+	}
+}
+
+func TestDevices(t *testing.T) {
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":1,"group":0,"devices":["iphone","work-phone"]}`)
+	})
+	p := load(t)
+	if d := p.Devices("work"); len(d) != 1 || d[0] != "work-phone" {
+		t.Errorf("configured devices of work are %v", d)
+	}
+	p.Devices("work")[0] = "changed"
+	if d := p.Devices("work"); d[0] != "work-phone" {
+		t.Errorf("devices changed through returned slice: %v", d)
+	}
+	if d := p.Devices("r1"); d != nil {
+		t.Errorf("receiver without restriction has devices %v", d)
+	}
+	if d := p.Devices("missing"); d != nil {
+		t.Errorf("unknown receiver has devices %v", d)
+	}
+	d, err := p.ActiveDevices(context.Background(), "a1", "r1")
+	if err != nil || len(d) != 2 || d[1] != "work-phone" {
+		t.Errorf("active devices %v, err=%v", d, err)
+	}
+}
+
+func TestValidateAll(t *testing.T) {
+	var calls atomic.Int32
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		r.ParseForm()
+		if r.Form.Get("user") == "bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"user":"invalid","errors":["user key is invalid"],"status":0}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":1,"group":0,"devices":["iphone"]}`)
+	})
+	p := load(t)
+	p.Rec["broken"] = Receiver{Key: "bad"}
+	results := p.ValidateAll(context.Background())
+	if len(results) != len(p.Rec) {
+		t.Fatalf("got %d results for %d receivers", len(results), len(p.Rec))
+	}
+	for rec, want := range map[string]error{"r1": nil, "r2": nil, "broken": ErrInvalidReceiver, "work": ErrNoActiveDevices} {
+		if err := results[rec]; !errors.Is(err, want) {
+			t.Errorf("validate %s returned %v, want %v", rec, err, want)
+		}
+	}
+	if calls.Load() != int32(len(p.Rec)) {
+		t.Errorf("made %d api calls for %d receivers", calls.Load(), len(p.Rec))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for rec, err := range p.ValidateAll(ctx) {
+		if err != context.Canceled {
+			t.Errorf("validate %s with cancelled context returned %v", rec, err)
+		}
+	}
+}
+
+func TestSendTest(t *testing.T) {
+	var titles []string
+	mock(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		titles = append(titles, r.PostForm.Get("title"))
+		fmt.Fprint(w, `{"status":1,"request":"req"}`)
+	})
+	m := message(t)
+	m.Throttle(time.Hour)
+	m.DedupWindow(time.Hour)
+	for i := 0; i < 2; i++ {
+		if err := m.SendTest(); err != nil {
+			t.Fatalf("test notification #%d returned %s", i, err)
+		}
+	}
+	if len(titles) != 2 || titles[0] != testTitle {
+		t.Errorf("test notifications posted titles %q", titles)
+	}
+	if !m.LastSent().IsZero() {
+		t.Errorf("test notification advanced the throttle timer")
+	}
+}